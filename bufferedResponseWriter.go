@@ -0,0 +1,186 @@
+package goat
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+//ErrResponseCommitted is returned when the body of a BufferedResponseWriter is replaced after it was sent
+var ErrResponseCommitted = errors.New("goat: response already committed")
+
+//BufferedResponseWriter holds the status and body of the response back until it is committed, so a middleware can
+//inspect and replace them and change the headers first. The response is committed by Commit or Finish, or earlier
+//when the body grows beyond the max size or the handler calls Flush, the rest of the response is then streamed.
+//Before funcs run when the response is committed. Status and Written report the held back response,
+//Size and ContentSize only count what was sent
+type BufferedResponseWriter interface {
+	ResponseWriter
+	// Buffered reports whether the response is still held back.
+	Buffered() bool
+	// Body returns the body held back so far, nil once the response is committed.
+	Body() []byte
+	// SetBody replaces the body held back, it returns ErrResponseCommitted once the response is committed.
+	SetBody(body []byte) error
+	// Commit sends the status, headers and body held back, later writes go straight to the wrapped writer.
+	Commit()
+}
+
+//NewBufferedResponseWriter func creates a BufferedResponseWriter which holds back at most maxSize bytes of body
+func NewBufferedResponseWriter(rw http.ResponseWriter, maxSize int) BufferedResponseWriter {
+	brw := &bufferedResponseWriter{
+		responseWriter: &responseWriter{
+			ResponseWriter: rw,
+			start:          time.Now(),
+		},
+		maxSize:   maxSize,
+		buffering: true,
+	}
+	self := wrapBufferedResponseWriter(brw)
+	brw.self = self
+	return self
+}
+
+//bufferedBaseWriter is the baseWriter of the buffered writer
+type bufferedBaseWriter interface {
+	baseWriter
+	Buffered() bool
+	Body() []byte
+	SetBody(body []byte) error
+	Commit()
+}
+
+type bufferedResponseWriter struct {
+	*responseWriter
+	maxSize   int
+	buffering bool
+	//status is the status held back, 0 until WriteHeader or Write is called
+	status  int
+	buf     bytes.Buffer
+	replace bool
+}
+
+func (bw *bufferedResponseWriter) WriteHeader(s int) {
+	if !bw.buffering || informational(s) {
+		bw.responseWriter.WriteHeader(s)
+		return
+	}
+	//like net/http only the first status counts
+	if bw.status == 0 {
+		bw.status = s
+	}
+}
+
+func (bw *bufferedResponseWriter) Write(b []byte) (int, error) {
+	if !bw.buffering {
+		return bw.responseWriter.Write(b)
+	}
+	if bw.status == 0 {
+		bw.status = http.StatusOK
+	}
+	if bw.buf.Len()+len(b) > bw.maxSize {
+		bw.commit(false)
+		return bw.responseWriter.Write(b)
+	}
+	return bw.buf.Write(b)
+}
+
+func (bw *bufferedResponseWriter) Status() int {
+	if bw.buffering {
+		return bw.status
+	}
+	return bw.responseWriter.Status()
+}
+
+func (bw *bufferedResponseWriter) Written() bool {
+	return bw.Status() != 0
+}
+
+func (bw *bufferedResponseWriter) Buffered() bool {
+	return bw.buffering
+}
+
+func (bw *bufferedResponseWriter) Body() []byte {
+	if !bw.buffering {
+		return nil
+	}
+	return bw.buf.Bytes()
+}
+
+func (bw *bufferedResponseWriter) SetBody(body []byte) error {
+	if !bw.buffering {
+		return ErrResponseCommitted
+	}
+	bw.buf.Reset()
+	bw.buf.Write(body)
+	bw.replace = true
+	if bw.status == 0 {
+		bw.status = http.StatusOK
+	}
+	return nil
+}
+
+func (bw *bufferedResponseWriter) Commit() {
+	bw.commit(true)
+}
+
+//commit sends what is held back, complete tells whether the body held back is the whole body
+func (bw *bufferedResponseWriter) commit(complete bool) {
+	if !bw.buffering {
+		return
+	}
+	bw.buffering = false
+	if bw.status == 0 {
+		//nothing was written, leave the response to whoever writes next
+		return
+	}
+	headers := bw.Header()
+	if complete {
+		if headers.Get("Content-Length") != "" {
+			headers.Set("Content-Length", strconv.Itoa(bw.buf.Len()))
+		}
+	} else if bw.replace {
+		//the length set by the handler does not match the replaced body and the total is not known yet
+		headers.Del("Content-Length")
+	}
+	bw.responseWriter.WriteHeader(bw.status)
+	if bw.buf.Len() > 0 {
+		bw.responseWriter.Write(bw.buf.Bytes())
+	}
+	bw.buf = bytes.Buffer{}
+}
+
+func (bw *bufferedResponseWriter) Finish() {
+	bw.Commit()
+	bw.responseWriter.Finish()
+}
+
+func (bw *bufferedResponseWriter) flush() {
+	bw.commit(false)
+	bw.responseWriter.flush()
+}
+
+func (bw *bufferedResponseWriter) hijack() (net.Conn, *bufio.ReadWriter, error) {
+	//the connection is handed over, nothing held back is sent anymore
+	bw.buffering = false
+	bw.buf = bytes.Buffer{}
+	return bw.responseWriter.hijack()
+}
+
+func (bw *bufferedResponseWriter) readFrom(src io.Reader) (int64, error) {
+	if !bw.buffering {
+		return bw.responseWriter.readFrom(src)
+	}
+	//copy through Write so the max size is honoured
+	return io.Copy(writerOnly{bw}, src)
+}
+
+//writerOnly hides the ReadFrom of the writer from io.Copy
+type writerOnly struct {
+	io.Writer
+}
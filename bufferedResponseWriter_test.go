@@ -0,0 +1,81 @@
+package goat
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_BufferedResponseWriter_ReplaceBody(t *testing.T) {
+	rr := httptest.NewRecorder()
+	bw := NewBufferedResponseWriter(rr, 1024)
+	before := 0
+	bw.Before(func(w ResponseWriter) {
+		before++
+		w.Header().Set("X-Before", "yes")
+	})
+
+	bw.Header().Set("Content-Length", "5")
+	bw.WriteHeader(http.StatusCreated)
+	bw.Write([]byte("hello"))
+	assert.True(t, bw.Buffered(), "Response not held back")
+	assert.True(t, bw.Written(), "Written must report the held back response")
+	assert.Equal(t, http.StatusCreated, bw.Status(), "Status does not match")
+	assert.Equal(t, "hello", string(bw.Body()), "Body does not match")
+	assert.Equal(t, 0, before, "Before func called before commit")
+	assert.False(t, rr.Flushed || rr.Body.Len() > 0, "Nothing may be sent before commit")
+
+	assert.NoError(t, bw.SetBody([]byte("HELLO WORLD")))
+	bw.Header().Set("X-After-Handler", "yes")
+	bw.Finish()
+
+	assert.Equal(t, 1, before, "Before func not called once")
+	assert.Equal(t, http.StatusCreated, rr.Code, "Status not sent")
+	assert.Equal(t, "HELLO WORLD", rr.Body.String(), "Body not replaced")
+	assert.Equal(t, "11", rr.Header().Get("Content-Length"), "Content-Length not fixed")
+	assert.Equal(t, "yes", rr.Header().Get("X-Before"), "Before header missing")
+	assert.Equal(t, "yes", rr.Header().Get("X-After-Handler"), "Header set after the handler missing")
+	assert.Equal(t, 11, bw.Size(), "Size does not match")
+	assert.Equal(t, ErrResponseCommitted, bw.SetBody(nil), "SetBody must fail after commit")
+}
+
+func Test_BufferedResponseWriter_Overflow(t *testing.T) {
+	rr := httptest.NewRecorder()
+	bw := NewBufferedResponseWriter(rr, 8)
+	bw.Write([]byte("1234"))
+	assert.True(t, bw.Buffered(), "Response not held back")
+	bw.Write([]byte("56789"))
+	assert.False(t, bw.Buffered(), "Response must stream once the max size is exceeded")
+	bw.Write([]byte("0"))
+	bw.Finish()
+	assert.Equal(t, "1234567890", rr.Body.String(), "Body does not match")
+	assert.Nil(t, bw.Body(), "Body must be nil after commit")
+}
+
+func Test_BufferedResponseWriter_Flush(t *testing.T) {
+	rr := httptest.NewRecorder()
+	bw := NewBufferedResponseWriter(rr, 1024)
+	bw.Write([]byte("event"))
+	bw.(http.Flusher).Flush()
+	assert.False(t, bw.Buffered(), "Flush must commit the response")
+	assert.True(t, rr.Flushed, "Recorder not flushed")
+	assert.Equal(t, "event", rr.Body.String(), "Body not sent on Flush")
+}
+
+func Test_BufferedResponseWriter_ReadFrom(t *testing.T) {
+	inner := &fullWriter{}
+	bw := NewBufferedResponseWriter(inner, 1024)
+	assert.Equal(t, interfacesOf(inner), interfacesOf(bw), "Interfaces not kept")
+
+	n, err := bw.(io.ReaderFrom).ReadFrom(strings.NewReader("from file"))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(9), n, "Copied size does not match")
+	assert.True(t, bw.Buffered(), "ReadFrom must go through the buffer")
+	assert.False(t, inner.readFrom, "ReadFrom of the wrapped writer used while buffering")
+	bw.Finish()
+	assert.Equal(t, "from file", inner.body.String(), "Body does not match")
+}
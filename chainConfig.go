@@ -0,0 +1,153 @@
+package goat
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+//ConfigError is returned when a chain config is invalid, Path points at the offending value
+//e.g. chains.api[1].options.level
+type ConfigError struct {
+	Path    string
+	Message string
+}
+
+func (e *ConfigError) Error() string {
+	return e.Path + ": " + e.Message
+}
+
+func configErrorf(path string, format string, args ...interface{}) error {
+	return &ConfigError{
+		Path:    path,
+		Message: fmt.Sprintf(format, args...),
+	}
+}
+
+//LoadChains func reads a chain config file and builds every chain declared in it.
+//Any format viper understands can be used (json, yaml, toml ...), the format is taken from the file extension.
+//A chain is a list of middleware names or of objects with a name and options
+//
+//	chains:
+//	  api:
+//	    - Logger
+//	    - Recovery
+//	    - name: Compression
+//	      options:
+//	        level: 6
+//
+//Keys are case insensitive, viper returns them lower cased so chain names are lower cased too
+func LoadChains(path string) (map[string]MiddlewareChain, error) {
+	v := viper.New()
+	v.SetConfigFile(path)
+	if err := v.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("reading chain config %s: %w", path, err)
+	}
+	return BuildChains(v.AllSettings())
+}
+
+//BuildChains func builds the chains from an already decoded config, see LoadChains for the layout
+func BuildChains(config map[string]interface{}) (map[string]MiddlewareChain, error) {
+	raw, ok := config["chains"]
+	if !ok {
+		return nil, configErrorf("chains", "missing")
+	}
+	declared, ok := raw.(map[string]interface{})
+	if !ok {
+		return nil, configErrorf("chains", "must be a map of chain names to middleware lists, got %T", raw)
+	}
+
+	//build in a fixed order so the first error reported is always the same one
+	names := make([]string, 0, len(declared))
+	for name := range declared {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	chains := make(map[string]MiddlewareChain, len(declared))
+	for _, name := range names {
+		chain, err := buildChain("chains."+name, declared[name])
+		if err != nil {
+			return nil, err
+		}
+		chains[name] = chain
+	}
+	return chains, nil
+}
+
+func buildChain(path string, raw interface{}) (MiddlewareChain, error) {
+	entries, ok := raw.([]interface{})
+	if !ok {
+		return MiddlewareChain{}, configErrorf(path, "must be a list of middlewares, got %T", raw)
+	}
+	var middlewares []NamedMiddleware
+	for i, entry := range entries {
+		m, err := buildEntry(fmt.Sprintf("%s[%d]", path, i), entry)
+		if err != nil {
+			return MiddlewareChain{}, err
+		}
+		middlewares = append(middlewares, m)
+	}
+	return NewNamed(middlewares...), nil
+}
+
+func buildEntry(path string, raw interface{}) (NamedMiddleware, error) {
+	var name string
+	var options map[string]interface{}
+
+	switch entry := raw.(type) {
+	case string:
+		name = entry
+	case map[string]interface{}:
+		for key, value := range entry {
+			switch strings.ToLower(key) {
+			case "name":
+				s, ok := value.(string)
+				if !ok {
+					return NamedMiddleware{}, configErrorf(path+".name", "must be a string, got %T", value)
+				}
+				name = s
+			case "options":
+				if value == nil {
+					continue
+				}
+				o, ok := value.(map[string]interface{})
+				if !ok {
+					return NamedMiddleware{}, configErrorf(path+".options", "must be a map, got %T", value)
+				}
+				options = o
+			default:
+				return NamedMiddleware{}, configErrorf(path+"."+key, "unknown field, expected name or options")
+			}
+		}
+		if name == "" {
+			return NamedMiddleware{}, configErrorf(path+".name", "missing")
+		}
+	default:
+		return NamedMiddleware{}, configErrorf(path, "must be a middleware name or an object with name and options, got %T", raw)
+	}
+
+	registered, ok := lookupMiddleware(name)
+	if !ok {
+		return NamedMiddleware{}, configErrorf(path+".name", "unknown middleware %q, registered middlewares are %s", name, strings.Join(RegisteredMiddlewares(), ", "))
+	}
+
+	o := newOptions(path+".options", options)
+	m, err := registered.factory(o)
+	if err == nil {
+		err = o.Err()
+	}
+	if err != nil {
+		if _, ok := err.(*ConfigError); !ok {
+			err = configErrorf(path, "%s: %v", registered.name, err)
+		}
+		return NamedMiddleware{}, err
+	}
+	if unknown := o.unread(); len(unknown) != 0 {
+		return NamedMiddleware{}, configErrorf(o.path+"."+unknown[0], "unknown option for %s", registered.name)
+	}
+	//stateful middlewares like RecoverAndLogPanic keep their Lifecycle so the chain can close them
+	return Managed(registered.name, m, lifecycleFor(m)), nil
+}
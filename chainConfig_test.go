@@ -0,0 +1,128 @@
+package goat
+
+import (
+	"compress/gzip"
+	"errors"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeChainConfig(t *testing.T, name string, content string) string {
+	dir, err := ioutil.TempDir("", "goat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func Test_LoadChains_YAML(t *testing.T) {
+	path := writeChainConfig(t, "chains.yaml", `
+chains:
+  api:
+    - Logger
+    - Recovery
+    - NoCache
+    - name: Compression
+      options:
+        level: 9
+  site:
+    - name: CSP
+      options:
+        default-src: ["'self'", "s1.rdbuz.com"]
+        script-src: "'self'"
+        directives:
+          fenced-frame-src: ["https://ads.example.com"]
+    - xss
+`)
+	chains, err := LoadChains(path)
+	if !assert.NoError(t, err, "Loading chains failed") {
+		return
+	}
+	assert.Equal(t, []string{"Logger", "Recovery", "NoCache", "Compression"}, chains["api"].Names(), "api chain does not match")
+	assert.Equal(t, []string{"CSP", "XSS"}, chains["site"].Names(), "site chain does not match")
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/", nil)
+	chains["site"].Then(&TestNoCacheHandler{}).ServeHTTP(rr, req)
+	assert.Equal(t, "default-src 'self' s1.rdbuz.com; script-src 'self'; fenced-frame-src https://ads.example.com", rr.Header().Get("Content-Security-Policy"), "CSP options not applied")
+	assert.Equal(t, "1; mode=block", rr.Header().Get("X-XSS-Protection"), "XSS not applied")
+
+	rr = httptest.NewRecorder()
+	req.Header.Set("Accept-Encoding", "gzip")
+	chains["api"].Then(&TestCompressionHandler{}).ServeHTTP(rr, req)
+	reader, err := gzip.NewReader(rr.Body)
+	if assert.NoError(t, err, "Response not gzipped") {
+		b, _ := ioutil.ReadAll(reader)
+		assert.Equal(t, "this is compression test", string(b), "Compressed body does not match")
+	}
+}
+
+func Test_LoadChains_JSON(t *testing.T) {
+	path := writeChainConfig(t, "chains.json", `{
+	"chains": {
+		"api": [
+			{"name": "Logger", "options": {"format": "{{.Method}} {{.Path}} {{.Status}}"}},
+			{"name": "Compression", "options": {"level": 1}}
+		]
+	}
+}`)
+	chains, err := LoadChains(path)
+	if !assert.NoError(t, err, "Loading chains failed") {
+		return
+	}
+	assert.Equal(t, []string{"Logger", "Compression"}, chains["api"].Names(), "api chain does not match")
+}
+
+func Test_LoadChains_Lifecycle(t *testing.T) {
+	path := writeChainConfig(t, "chains.json", `{"chains": {"api": ["NoCache", "RecoverAndLogPanic"]}}`)
+	chains, err := LoadChains(path)
+	if !assert.NoError(t, err, "Loading chains failed") {
+		return
+	}
+	assert.NotNil(t, chains["api"].List()[1].Lifecycle, "Loaded chain dropped the Lifecycle of the middleware")
+}
+
+func Test_LoadChains_Errors(t *testing.T) {
+	tests := []struct {
+		config string
+		path   string
+	}{
+		{`{"chains": {"api": [{"name": "Compression", "options": {"level": "fast"}}]}}`, "chains.api[0].options.level"},
+		{`{"chains": {"api": [{"name": "Compression", "options": {"level": 42}}]}}`, "chains.api[0].options.level"},
+		{`{"chains": {"api": [{"name": "Compression", "options": {"min-size": -1}}]}}`, "chains.api[0].options.min-size"},
+		{`{"chains": {"api": [{"name": "Compression", "options": {"content-types": ["text/["]}}]}}`, "chains.api[0].options.content-types"},
+		{`{"chains": {"api": [{"name": "Compression", "options": {"excluded-content-types": ["text/["]}}]}}`, "chains.api[0].options.excluded-content-types"},
+		{`{"chains": {"api": ["Logger", "Gzip"]}}`, "chains.api[1].name"},
+		{`{"chains": {"api": ["Logger", {"name": "NoCache", "options": {"max-age": 10}}]}}`, "chains.api[1].options.max-age"},
+		{`{"chains": {"api": [{"name": "CSP", "options": {"script-src": ["'self'", 1]}}]}}`, "chains.api[0].options.script-src[1]"},
+		{`{"chains": {"api": [{"name": "CSP", "options": {"script-src": ["self"]}}]}}`, "chains.api[0].options.script-src"},
+		{`{"chains": {"api": [{"name": "CSP", "options": {"default-src": "'self'", "plugin-types": "application/pdf"}}]}}`, "chains.api[0].options.plugin-types"},
+		{`{"chains": {"api": [{"name": "CSP", "options": {"directives": {"fenced-frame-src": ["a;b"]}}}]}}`, "chains.api[0].options.directives.fenced-frame-src"},
+		{`{"chains": {"api": [{"name": "CSP", "options": {"directives": {"fenced-frame-src": [1]}}}]}}`, "chains.api[0].options.directives.fenced-frame-src[0]"},
+		{`{"chains": {"api": [{"name": "CSP", "options": {"directives": ["fenced-frame-src"]}}]}}`, "chains.api[0].options.directives"},
+		{`{"chains": {"api": [{"name": "Logger", "options": {"format": "{{.Status"}}]}}`, "chains.api[0].options.format"},
+		{`{"chains": {"api": [{"options": {}}]}}`, "chains.api[0].name"},
+		{`{"chains": {"api": "Logger"}}`, "chains.api"},
+		{`{"middlewares": []}`, "chains"},
+	}
+	for _, tt := range tests {
+		_, err := LoadChains(writeChainConfig(t, "chains.json", tt.config))
+		var configErr *ConfigError
+		if assert.True(t, errors.As(err, &configErr), "No ConfigError for %s: %v", tt.config, err) {
+			assert.Equal(t, tt.path, configErr.Path, "Error path does not match for %s", tt.config)
+		}
+	}
+
+	_, err := LoadChains(filepath.Join(os.TempDir(), "missing-goat-chains.json"))
+	assert.Error(t, err, "Missing file not reported")
+}
@@ -0,0 +1,98 @@
+package goat
+
+import (
+	"errors"
+	"fmt"
+)
+
+//ErrMiddlewareNotFound is returned by the chain editing funcs when no middleware with the given name is in the chain
+var ErrMiddlewareNotFound = errors.New("middleware not found in chain")
+
+func notFound(name string) error {
+	return fmt.Errorf("%w: %s", ErrMiddlewareNotFound, name)
+}
+
+//Index func returns the position of the first middleware with the given name, or -1 if it is not in the chain
+func (mc MiddlewareChain) Index(name string) int {
+	for i, m := range mc.middlewares {
+		if m.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+//Contains func reports whether a middleware with the given name is in the chain
+func (mc MiddlewareChain) Contains(name string) bool {
+	return mc.Index(name) >= 0
+}
+
+//splice builds a new chain from the middlewares before i, the inserted ones and the middlewares from j on.
+//The result never shares its backing array with mc
+func (mc MiddlewareChain) splice(i, j int, inserted []NamedMiddleware) MiddlewareChain {
+	middlewares := make([]NamedMiddleware, 0, len(mc.middlewares)-(j-i)+len(inserted))
+	middlewares = append(middlewares, mc.middlewares[:i]...)
+	middlewares = append(middlewares, inserted...)
+	middlewares = append(middlewares, mc.middlewares[j:]...)
+	return MiddlewareChain{
+		middlewares: middlewares,
+	}
+}
+
+//Prepend func creates a new chain with the middlewares added in front of the original chain
+func (mc MiddlewareChain) Prepend(middlewares ...Middleware) MiddlewareChain {
+	return mc.splice(0, 0, nameAll(middlewares))
+}
+
+//InsertBefore func creates a new chain with the middlewares added right before the first middleware with the given name
+func (mc MiddlewareChain) InsertBefore(name string, middlewares ...Middleware) (MiddlewareChain, error) {
+	i := mc.Index(name)
+	if i < 0 {
+		return mc, notFound(name)
+	}
+	return mc.splice(i, i, nameAll(middlewares)), nil
+}
+
+//InsertAfter func creates a new chain with the middlewares added right after the first middleware with the given name
+func (mc MiddlewareChain) InsertAfter(name string, middlewares ...Middleware) (MiddlewareChain, error) {
+	i := mc.Index(name)
+	if i < 0 {
+		return mc, notFound(name)
+	}
+	return mc.splice(i+1, i+1, nameAll(middlewares)), nil
+}
+
+//Remove func creates a new chain without the first middleware with the given name
+func (mc MiddlewareChain) Remove(name string) (MiddlewareChain, error) {
+	i := mc.Index(name)
+	if i < 0 {
+		return mc, notFound(name)
+	}
+	return mc.splice(i, i+1, nil), nil
+}
+
+//Replace func creates a new chain where the first middleware with the given name is swapped for another one,
+//the new middleware is listed under its own name and keeps its Lifecycle
+func (mc MiddlewareChain) Replace(name string, middleware Middleware) (MiddlewareChain, error) {
+	return mc.ReplaceNamed(name, nameAll([]Middleware{middleware})[0])
+}
+
+//ReplaceNamed func is similar to Replace but accepts a middleware that already carries a name
+func (mc MiddlewareChain) ReplaceNamed(name string, middleware NamedMiddleware) (MiddlewareChain, error) {
+	i := mc.Index(name)
+	if i < 0 {
+		return mc, notFound(name)
+	}
+	return mc.splice(i, i+1, []NamedMiddleware{middleware}), nil
+}
+
+//Merge func creates a new chain with the middlewares of the other chains added after the ones of the original chain
+func (mc MiddlewareChain) Merge(chains ...MiddlewareChain) MiddlewareChain {
+	merged := mc.List()
+	for _, c := range chains {
+		merged = append(merged, c.middlewares...)
+	}
+	return MiddlewareChain{
+		middlewares: merged,
+	}
+}
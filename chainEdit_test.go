@@ -0,0 +1,83 @@
+package goat
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Chain_Prepend(t *testing.T) {
+	mc := CommonMiddlewares()
+	prepended := mc.Prepend(XSS)
+	assert.Equal(t, []string{"XSS", "NoCache", "Recovery", "Logger"}, prepended.Names(), "Prepend order does not match")
+	assert.Equal(t, []string{"NoCache", "Recovery", "Logger"}, mc.Names(), "Original chain changed")
+}
+
+func Test_Chain_Insert(t *testing.T) {
+	mc := CommonMiddlewares()
+
+	before, err := mc.InsertBefore("Logger", XSS)
+	assert.NoError(t, err, "InsertBefore failed")
+	assert.Equal(t, []string{"NoCache", "Recovery", "XSS", "Logger"}, before.Names(), "InsertBefore order does not match")
+
+	after, err := mc.InsertAfter("Logger", XSS, Compression)
+	assert.NoError(t, err, "InsertAfter failed")
+	assert.Equal(t, []string{"NoCache", "Recovery", "Logger", "XSS", "Compression"}, after.Names(), "InsertAfter order does not match")
+
+	_, err = mc.InsertBefore("Missing", XSS)
+	assert.ErrorIs(t, err, ErrMiddlewareNotFound, "Missing middleware not reported")
+	assert.Equal(t, []string{"NoCache", "Recovery", "Logger"}, mc.Names(), "Original chain changed")
+}
+
+func Test_Chain_Remove(t *testing.T) {
+	mc := CommonMiddlewares()
+	removed, err := mc.Remove("Recovery")
+	assert.NoError(t, err, "Remove failed")
+	assert.Equal(t, []string{"NoCache", "Logger"}, removed.Names(), "Remove did not remove the middleware")
+	assert.False(t, removed.Contains("Recovery"), "Removed middleware still in chain")
+
+	_, err = mc.Remove("Missing")
+	assert.ErrorIs(t, err, ErrMiddlewareNotFound, "Missing middleware not reported")
+	assert.Equal(t, []string{"NoCache", "Recovery", "Logger"}, mc.Names(), "Original chain changed")
+}
+
+func Test_Chain_Replace(t *testing.T) {
+	mc := CommonMiddlewares()
+	replaced, err := mc.Replace("Recovery", RecoverAndLogPanic)
+	assert.NoError(t, err, "Replace failed")
+	assert.Equal(t, []string{"NoCache", "RecoverAndLogPanic", "Logger"}, replaced.Names(), "Replace did not swap the middleware")
+	assert.NotNil(t, replaced.List()[1].Lifecycle, "Replace dropped the Lifecycle of the middleware")
+
+	server := httptest.NewServer(replaced.Then(&TestPanicHandler{}))
+	defer server.Close()
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, http.StatusInternalServerError, resp.StatusCode, "Replaced recovery did not recover")
+
+	named, err := mc.ReplaceNamed("Logger", Named("Quiet", sampleMiddleware))
+	assert.NoError(t, err, "ReplaceNamed failed")
+	assert.Equal(t, []string{"NoCache", "Recovery", "Quiet"}, named.Names(), "ReplaceNamed did not use the name")
+	assert.Equal(t, []string{"NoCache", "Recovery", "Logger"}, mc.Names(), "Original chain changed")
+}
+
+func Test_Chain_Merge(t *testing.T) {
+	mc := CommonMiddlewares()
+	merged := mc.Merge(New(XSS), New(Compression))
+	assert.Equal(t, []string{"NoCache", "Recovery", "Logger", "XSS", "Compression"}, merged.Names(), "Merge order does not match")
+	assert.Equal(t, 3, mc.Len(), "Original chain changed")
+}
+
+func Test_Chain_AppendToChain_NoAlias(t *testing.T) {
+	//leave spare capacity in the backing array so a plain append would share it
+	middlewares := make([]NamedMiddleware, 0, 8)
+	base := MiddlewareChain{middlewares: append(middlewares, CommonMiddlewares().List()...)}
+	first := base.AppendToChain(Compression)
+	second := base.AppendToChain(XSS)
+	assert.Equal(t, "Compression", first.Names()[3], "First chain was overwritten by the second append")
+	assert.Equal(t, "XSS", second.Names()[3], "Second append does not match")
+	assert.Equal(t, 3, base.Len(), "Original chain changed")
+}
@@ -1,82 +1,478 @@
 package goat
 
 import (
-	"compress/gzip"
+	"bufio"
+	"fmt"
 	"io"
 	"io/ioutil"
+	"net"
 	"net/http"
+	"path"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
 )
 
-//gziResponseWriter wraps the io.Writer and ResponseWrirer
-type gzipResponseWriter struct {
-	io.Writer
-	ResponseWriter
+//CompressionOptions struct configures a Compressor
+type CompressionOptions struct {
+	//Level is passed to every encoder, nil stands for DefaultCompression.
+	//It is a pointer so gzip.NoCompression, which is 0, can be selected too, LevelOf creates one
+	Level *int
+	//MinSize is the smallest body in bytes that gets compressed, smaller bodies are sent as they are.
+	//Up to MinSize bytes are held back until the size is known
+	MinSize int
+	//ContentTypes lists the media types that get compressed, e.g. text/* or application/json, empty means all.
+	//Patterns use the syntax of path.Match and are matched without parameters like charset
+	ContentTypes []string
+	//ExcludedContentTypes lists the media types that are never compressed, it wins over ContentTypes
+	ExcludedContentTypes []string
 }
 
-//Need to implement Write func because io.Writer interface needs this func
-func (w gzipResponseWriter) Write(b []byte) (int, error) {
-	return w.Writer.Write(b)
+//alreadyCompressedTypes are media types whose data is compressed already, compressing them again only costs time
+var alreadyCompressedTypes = []string{
+	"image/png", "image/jpeg", "image/gif", "image/webp", "image/avif", "image/heic",
+	"video/*", "audio/*", "font/woff", "font/woff2",
+	"application/zip", "application/gzip", "application/x-gzip", "application/x-bzip2",
+	"application/x-xz", "application/x-7z-compressed", "application/x-rar-compressed", "application/zstd",
+	"application/pdf",
+}
+
+//LevelOf func returns a pointer to the level for CompressionOptions
+func LevelOf(level int) *int {
+	return &level
+}
+
+//streamedTypes are media types whose events have to reach the client one by one, an encoder would hold them back
+var streamedTypes = []string{"text/event-stream"}
+
+//DefaultCompressionOptions func returns the options of the Compression middleware,
+//every response is compressed with the default level unless its media type is compressed already or streamed
+func DefaultCompressionOptions() CompressionOptions {
+	return CompressionOptions{
+		ExcludedContentTypes: append(append([]string(nil), alreadyCompressedTypes...), streamedTypes...),
+	}
 }
 
-//Handler struct provides pool of gzipWriter which can be reused many times from the pool
+//Compressor struct provides a pool of writers per encoder which can be reused many times from the pool
 //Dont exactly understand why but saw it in https://github.com/NYTimes/gziphandler
+type Compressor struct {
+	options  CompressionOptions
+	encoders []Encoder
+	pools    map[string]*sync.Pool
+	counter  compressionCounter
+}
+
+//CompressionError is returned by NewCompressor for an invalid option,
+//Option names the option like in chain config files, e.g. min-size or excluded-content-types
+type CompressionError struct {
+	Option  string
+	Message string
+	Err     error //the underlying error, nil if the option was checked by goat itself
+}
+
+func (e *CompressionError) Error() string {
+	return "goat: compression " + e.Option + ": " + e.Message
+}
+
+//Unwrap func returns the underlying error
+func (e *CompressionError) Unwrap() error {
+	return e.Err
+}
+
+//NewCompressor func creates a Compressor for the encoders registered so far,
+//it fails with a *CompressionError if an encoder rejects the level or a content type pattern is malformed
+func NewCompressor(options CompressionOptions) (*Compressor, error) {
+	level := DefaultCompression
+	if options.Level != nil {
+		level = *options.Level
+	}
+	if options.MinSize < 0 {
+		return nil, &CompressionError{Option: "min-size", Message: fmt.Sprintf("must not be negative, got %d", options.MinSize)}
+	}
+	if err := checkPatterns(options.ContentTypes); err != nil {
+		return nil, &CompressionError{Option: "content-types", Message: err.Error(), Err: err}
+	}
+	if err := checkPatterns(options.ExcludedContentTypes); err != nil {
+		return nil, &CompressionError{Option: "excluded-content-types", Message: err.Error(), Err: err}
+	}
+	c := &Compressor{
+		options:  options,
+		encoders: registeredEncoders(),
+		pools:    map[string]*sync.Pool{},
+	}
+	for _, encoder := range c.encoders {
+		if _, err := encoder.NewWriter(ioutil.Discard, level); err != nil {
+			return nil, &CompressionError{Option: "level", Message: fmt.Sprintf("%s rejects level %d: %v", encoder.Encoding(), level, err), Err: err}
+		}
+		encoder := encoder
+		c.pools[encoder.Encoding()] = &sync.Pool{
+			New: func() interface{} {
+				//write the compressed data to the writer using the configured level
+				ew, err := encoder.NewWriter(ioutil.Discard, level)
+				if err != nil {
+					panic(err)
+				}
+				return ew
+			},
+		}
+	}
+	return c, nil
+}
+
+//Compression middleware compresses the responses with the encoding the client prefers
+func (c *Compressor) Compression(next http.Handler) http.Handler {
+	return &Handler{
+		compressor: c,
+		next:       next,
+	}
+}
+
+//compressible reports whether responses of the content type get compressed
+func (c *Compressor) compressible(contentType string) bool {
+	mediaType := strings.ToLower(strings.TrimSpace(strings.Split(contentType, ";")[0]))
+	if mediaType == "" {
+		return false
+	}
+	if matchesAny(c.options.ExcludedContentTypes, mediaType) {
+		return false
+	}
+	return len(c.options.ContentTypes) == 0 || matchesAny(c.options.ContentTypes, mediaType)
+}
+
+//checkPatterns returns an error for the first malformed content type pattern
+func checkPatterns(patterns []string) error {
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid content type pattern %q: %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+func matchesAny(patterns []string, mediaType string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(strings.ToLower(pattern), mediaType); ok {
+			return true
+		}
+	}
+	return false
+}
+
+//Handler struct is the compression middleware wrapped around the next handler
 type Handler struct {
-	pool sync.Pool
-	next http.Handler
+	compressor *Compressor
+	next       http.Handler
 }
 
 //ServeHTTP func needs to ge implemented because http.handler interface needs this method otherwise Handler will not become a http.Handler
 func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	if !strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
-		//if header does not have Accept-Encoding header just move on
+	//the response depends on Accept-Encoding even when it is not compressed
+	addVary(w.Header(), "Accept-Encoding")
+
+	encoder := negotiateEncoding(r.Header.Get("Accept-Encoding"), h.compressor.encoders)
+	if encoder == nil {
+		//if the client accepts none of our encodings just move on
 		h.next.ServeHTTP(w, r)
+		h.compressor.recordCompression(r, compressionResult{skipped: SkipNotAccepted})
 		return
 	}
 
-	if w.Header().Get("Content-Encoding") == "gzip" {
-		//if already compressed move on
-		h.next.ServeHTTP(w, r)
+	//wrap responseWriter to our response writer
+	nrw := NewResponseWriter(w)
+	//the After funcs run even if the handler panics, nothing held back is written then
+	defer nrw.Finish()
+	crw := &compressResponseWriter{
+		ResponseWriter: nrw,
+		compressor:     h.compressor,
+		encoder:        encoder,
+		//responses to HEAD have no body to compress
+		head: r.Method == http.MethodHead,
+	}
+	//the next handler sees exactly the optional interfaces of the original writer
+	h.next.ServeHTTP(wrapResponseWriter(crw), r)
+	crw.close()
+	if !crw.hijacked {
+		h.compressor.recordCompression(r, crw.result(nrw))
+	}
+}
+
+//compressResponseWriter holds the status and up to MinSize bytes back until it knows whether to compress,
+//then it either encodes the body or passes it through
+type compressResponseWriter struct {
+	ResponseWriter
+	compressor *Compressor
+	encoder    Encoder
+	//status and buf are held back until decided
+	status  int
+	buf     []byte
+	decided bool
+	//ew is nil when the body is passed through
+	ew       EncodeWriter
+	head     bool
+	hijacked bool
+	//skipped is the reason the response was passed through, bytesIn and encodeTime are counted for the stats.
+	//sinkTime is the time the encoder spent writing to the goat writer below, it is left out of encodeTime
+	skipped    string
+	bytesIn    int64
+	encodeTime time.Duration
+	sinkTime   time.Duration
+}
+
+//Unwrap func returns the goat writer the encoder writes into
+func (w *compressResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+//the compressResponseWriter reports the content sizes itself, the goat writers below get them from Write
+func (w *compressResponseWriter) setEncoded()         {}
+func (w *compressResponseWriter) addContent(size int) {}
+
+//hijack hands the connection over for websocket upgrades, nothing held back is sent anymore
+func (w *compressResponseWriter) hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := w.ResponseWriter.(http.Hijacker).Hijack()
+	if err == nil {
+		w.decided = true
+		w.hijacked = true
+		w.buf = nil
+	}
+	return conn, rw, err
+}
+
+func (w *compressResponseWriter) WriteHeader(status int) {
+	if w.decided || informational(status) {
+		w.ResponseWriter.WriteHeader(status)
 		return
 	}
+	if w.status == 0 {
+		w.status = status
+	}
+	//decide right away when the headers already tell
+	if reason := w.headerSkipReason(); reason != "" {
+		w.decide(reason)
+	} else if w.compressor.options.MinSize == 0 && w.Header().Get("Content-Type") != "" {
+		w.decide("")
+	}
+}
 
-	//inspired by https://github.com/NYTimes/gziphandler
-	//get gzip Writer from pool
-	gz := h.pool.Get().(*gzip.Writer)
-	//dont forget to put to back into the pool after writing
-	defer h.pool.Put(gz)
+//Status func reports the status held back too
+func (w *compressResponseWriter) Status() int {
+	if !w.decided {
+		return w.status
+	}
+	return w.ResponseWriter.Status()
+}
+
+//Written func reports the response held back too
+func (w *compressResponseWriter) Written() bool {
+	return w.Status() != 0
+}
+
+//Need to implement Write func because io.Writer interface needs this func
+func (w *compressResponseWriter) Write(b []byte) (int, error) {
+	if !w.decided {
+		if w.status == 0 {
+			w.status = http.StatusOK
+		}
+		w.buf = append(w.buf, b...)
+		if len(w.buf) > 0 && len(w.buf) >= w.compressor.options.MinSize {
+			w.decide(w.skipReason(true))
+		}
+		return len(b), nil
+	}
+	if w.ew == nil {
+		return w.ResponseWriter.Write(b)
+	}
+	//the size before compression is reported to the goat writers below, they only see the compressed bytes
+	var n int
+	var err error
+	w.timeEncoder(func() {
+		n, err = w.ew.Write(b)
+	})
+	w.bytesIn += int64(n)
+	eachContentRecorder(w.ResponseWriter, func(r contentRecorder) {
+		r.addContent(n)
+	})
+	return n, err
+}
+
+//flush pushes the data buffered by the encoder to the client.
+//A response flushed before MinSize bytes were written is compressed if its content type allows it
+func (w *compressResponseWriter) flush() {
+	if !w.decided {
+		w.decide(w.skipReason(false))
+	}
+	if w.ew != nil {
+		w.timeEncoder(func() {
+			w.ew.Flush()
+		})
+	}
+	w.ResponseWriter.(http.Flusher).Flush()
+}
+
+//readFrom copies through Write so the body is encoded, sendfile is only kept for bodies passed through
+func (w *compressResponseWriter) readFrom(src io.Reader) (int64, error) {
+	if w.decided && w.ew == nil {
+		return w.ResponseWriter.(io.ReaderFrom).ReadFrom(src)
+	}
+	return io.Copy(writerOnly{w}, src)
+}
+
+//headerSkipReason returns why the request, the status or the headers alone rule out compression, "" if they do not
+func (w *compressResponseWriter) headerSkipReason() string {
+	if w.head || w.status != 0 && !bodyAllowed(w.status) {
+		return SkipNoBody
+	}
+	headers := w.Header()
+	if headers.Get("Content-Encoding") != "" {
+		//if already compressed move on
+		return SkipEncoded
+	}
+	if length, err := strconv.Atoi(headers.Get("Content-Length")); err == nil && length < w.compressor.options.MinSize {
+		return SkipTooSmall
+	}
+	if contentType := headers.Get("Content-Type"); contentType != "" && !w.compressor.compressible(contentType) {
+		return SkipContentType
+	}
+	return ""
+}
+
+//skipReason looks at the headers and the body held back and returns why the response is not compressed, "" if it is.
+//checkSize tells whether bodies below MinSize are refused
+func (w *compressResponseWriter) skipReason(checkSize bool) string {
+	if reason := w.headerSkipReason(); reason != "" {
+		return reason
+	}
+	if checkSize && len(w.buf) < w.compressor.options.MinSize {
+		return SkipTooSmall
+	}
+	headers := w.Header()
+	contentType := headers.Get("Content-Type")
+	if contentType == "" {
+		if len(w.buf) == 0 {
+			return SkipNoBody
+		}
+		//net/http would sniff the same type when the header goes out
+		contentType = http.DetectContentType(w.buf)
+		headers.Set("Content-Type", contentType)
+	}
+	if !w.compressor.compressible(contentType) {
+		return SkipContentType
+	}
+	return ""
+}
+
+//decide writes what was held back, compressed when there is no reason to skip or as it is
+func (w *compressResponseWriter) decide(skipReason string) {
+	w.decided = true
+	w.skipped = skipReason
+	if skipReason == "" {
+		w.startEncoding()
+	}
+	if w.status != 0 {
+		w.ResponseWriter.WriteHeader(w.status)
+	}
+	if len(w.buf) > 0 {
+		buf := w.buf
+		w.buf = nil
+		w.Write(buf)
+	}
+}
+
+func (w *compressResponseWriter) startEncoding() {
+	//get the writer of the encoder from its pool
+	ew := w.compressor.pools[w.encoder.Encoding()].Get().(EncodeWriter)
+	//the goat writers below count the compressed bytes as their Size and get the uncompressed ones reported
+	eachContentRecorder(w.ResponseWriter, func(r contentRecorder) {
+		r.setEncoded()
+	})
 	//Reset the responseWriter  to original state , this allows to resuse a writer rather than creating a new one
-	gz.Reset(w)
+	ew.Reset(encoderSink{w})
+	w.ew = ew
 
-	//set the required headers
+	//set the required headers, the length of the compressed body is not known
 	headers := w.Header()
-	headers.Set("Content-Encoding", "gzip")
-	headers.Set("Vary", "Accept-Encoding")
+	headers.Set("Content-Encoding", w.encoder.Encoding())
+	headers.Del("Content-Length")
+}
 
-	//wrap responseWriter to our response writer
-	nrw := NewResponseWriter(w)
-	//created gzipResponseWriter to pass to next handler
-	grw := gzipResponseWriter{
-		gz,
-		nrw,
+//close decides about responses smaller than MinSize and closes the encoder
+func (w *compressResponseWriter) close() {
+	if w.hijacked {
+		return
+	}
+	if !w.decided {
+		if w.status == 0 && len(w.buf) == 0 {
+			//nothing was written, leave the response to net/http
+			w.decided = true
+			w.skipped = SkipNoBody
+			return
+		}
+		w.decide(w.skipReason(true))
 	}
-	h.next.ServeHTTP(grw, r)
-	//close writer
-	gz.Close()
+	if w.ew != nil {
+		//close writer
+		w.timeEncoder(func() {
+			w.ew.Close()
+		})
+		//dont forget to put to back into the pool after writing
+		w.compressor.pools[w.encoder.Encoding()].Put(w.ew)
+		w.ew = nil
+	}
+}
+
+//result returns what the response contributes to the stats, nrw is the writer the encoder wrote into
+func (w *compressResponseWriter) result(nrw ResponseWriter) compressionResult {
+	if w.skipped != "" {
+		return compressionResult{skipped: w.skipped}
+	}
+	return compressionResult{
+		encoding:   w.encoder.Encoding(),
+		bytesIn:    w.bytesIn,
+		bytesOut:   int64(nrw.Size()),
+		encodeTime: w.encodeTime,
+	}
+}
+
+//timeEncoder adds the time encode spends in the encoder to encodeTime, its writes to the client are left out
+func (w *compressResponseWriter) timeEncoder(encode func()) {
+	start := time.Now()
+	sink := w.sinkTime
+	encode()
+	w.encodeTime += time.Since(start) - (w.sinkTime - sink)
 }
 
+//encoderSink is the writer the encoder writes into, it times the writes to the goat writer below
+type encoderSink struct {
+	w *compressResponseWriter
+}
+
+func (s encoderSink) Write(b []byte) (int, error) {
+	start := time.Now()
+	n, err := s.w.ResponseWriter.Write(b)
+	s.w.sinkTime += time.Since(start)
+	return n, err
+}
+
+//Compression middleware compresses the response with the encoding the client prefers, using DefaultCompressionOptions.
+//Every use creates its own Compressor whose Stats can not be read, create one with NewCompressor for them
+//or place a Monitor around the middleware
 func Compression(next http.Handler) http.Handler {
-	handler := &Handler{
-		next: next,
-	}
-	handler.pool.New = func() interface{} {
-		//write the compressed data to the writer using Default Compression
-		gz, err := gzip.NewWriterLevel(ioutil.Discard, gzip.DefaultCompression)
-		if err != nil {
-			panic(err)
-		}
-		return gz
+	c, err := NewCompressor(DefaultCompressionOptions())
+	if err != nil {
+		panic(err)
+	}
+	return c.Compression(next)
+}
+
+//CompressionLevel func creates a compression middleware which uses the given level for every registered encoder,
+//for gzip and deflate from gzip.HuffmanOnly to gzip.BestCompression, gzip.NoCompression sends the data stored but still encoded
+func CompressionLevel(level int) (Middleware, error) {
+	options := DefaultCompressionOptions()
+	options.Level = LevelOf(level)
+	c, err := NewCompressor(options)
+	if err != nil {
+		return nil, err
 	}
-	return handler
+	return c.Compression, nil
 }
@@ -0,0 +1,119 @@
+package goat
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+//reasons a response is not compressed, used as keys of CompressionStats.Skipped
+const (
+	SkipNotAccepted = "not-accepted" //the client accepts none of the encodings
+	SkipEncoded     = "encoded"      //the response has a Content-Encoding already
+	SkipNoBody      = "no-body"      //HEAD request, 204 or 304 response, or nothing written
+	SkipTooSmall    = "too-small"    //the body is smaller than MinSize
+	SkipContentType = "content-type" //the media type is excluded or not listed
+)
+
+//EncodingStats struct holds the numbers of the responses compressed with one encoding
+type EncodingStats struct {
+	Responses int64
+	//BytesIn counts the bytes before compression, BytesOut after
+	BytesIn  int64
+	BytesOut int64
+	//Ratio is BytesOut divided by BytesIn, 0.25 means the responses shrank to a quarter
+	Ratio float64
+	//EncodeTime is the time spent in the encoder, the writes of the compressed bytes to the client are left out
+	EncodeTime    time.Duration
+	EncodeTimeSec float64
+}
+
+//CompressionStats struct holds the numbers of the compression middleware, per encoding and per reason to skip a response
+type CompressionStats struct {
+	Encodings map[string]EncodingStats
+	Skipped   map[string]int64
+}
+
+//compressionResult is what one response contributes to the stats
+type compressionResult struct {
+	encoding   string //empty when skipped
+	skipped    string
+	bytesIn    int64
+	bytesOut   int64
+	encodeTime time.Duration
+}
+
+//compressionResultKey hands the result of a response to the Monitor around the compression middleware
+var compressionResultKey = NewKey[compressionResult]("compression-result")
+
+//compressionCounter adds up compression results, it is shared by Compressor and Monit
+type compressionCounter struct {
+	mu        sync.Mutex
+	encodings map[string]*EncodingStats
+	skipped   map[string]int64
+}
+
+func (c *compressionCounter) add(result compressionResult) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if result.encoding == "" {
+		if c.skipped == nil {
+			c.skipped = map[string]int64{}
+		}
+		c.skipped[result.skipped]++
+		return
+	}
+	if c.encodings == nil {
+		c.encodings = map[string]*EncodingStats{}
+	}
+	stats, ok := c.encodings[result.encoding]
+	if !ok {
+		stats = &EncodingStats{}
+		c.encodings[result.encoding] = stats
+	}
+	stats.Responses++
+	stats.BytesIn += result.bytesIn
+	stats.BytesOut += result.bytesOut
+	stats.EncodeTime += result.encodeTime
+}
+
+//empty reports whether no result was added yet
+func (c *compressionCounter) empty() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.encodings) == 0 && len(c.skipped) == 0
+}
+
+func (c *compressionCounter) stats() CompressionStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats := CompressionStats{
+		Encodings: make(map[string]EncodingStats, len(c.encodings)),
+		Skipped:   make(map[string]int64, len(c.skipped)),
+	}
+	for encoding, e := range c.encodings {
+		copied := *e
+		if copied.BytesIn > 0 {
+			copied.Ratio = float64(copied.BytesOut) / float64(copied.BytesIn)
+		}
+		copied.EncodeTimeSec = copied.EncodeTime.Seconds()
+		stats.Encodings[encoding] = copied
+	}
+	for reason, count := range c.skipped {
+		stats.Skipped[reason] = count
+	}
+	return stats
+}
+
+//Stats func returns the numbers of the responses the compressor has seen so far
+func (c *Compressor) Stats() CompressionStats {
+	return c.counter.stats()
+}
+
+//recordCompression adds the result to the compressor stats and hands it to a Monitor around the middleware
+func (c *Compressor) recordCompression(r *http.Request, result compressionResult) {
+	c.counter.add(result)
+	if StoreFrom(r.Context()) != nil {
+		Set(r, compressionResultKey, result)
+	}
+}
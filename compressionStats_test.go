@@ -0,0 +1,111 @@
+package goat
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Compressor_Stats(t *testing.T) {
+	c, err := NewCompressor(CompressionOptions{MinSize: 10, ContentTypes: []string{"text/*"}})
+	assert.NoError(t, err)
+	body := strings.Repeat("compress me ", 100)
+	handler := c.Compression(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/small":
+			fmt.Fprint(w, "tiny")
+		case "/json":
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, body)
+		case "/empty":
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.Header().Set("Content-Type", "text/plain")
+			fmt.Fprint(w, body)
+		}
+	}))
+	serve := func(path string, acceptEncoding string) {
+		req := httptest.NewRequest("GET", path, nil)
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	serve("/", "gzip")
+	serve("/", "deflate")
+	serve("/", "gzip")
+	serve("/", "")
+	serve("/small", "gzip")
+	serve("/json", "gzip")
+	serve("/empty", "gzip")
+
+	stats := c.Stats()
+	gzipStats := stats.Encodings["gzip"]
+	assert.Equal(t, int64(2), gzipStats.Responses, "Gzip responses not counted")
+	assert.Equal(t, int64(2*len(body)), gzipStats.BytesIn, "Uncompressed bytes not counted")
+	assert.True(t, gzipStats.BytesOut > 0 && gzipStats.BytesOut < gzipStats.BytesIn, "Compressed bytes not counted")
+	assert.InDelta(t, float64(gzipStats.BytesOut)/float64(gzipStats.BytesIn), gzipStats.Ratio, 0.0001, "Ratio does not match")
+	assert.True(t, gzipStats.EncodeTime > 0, "Encode time not measured")
+	assert.Equal(t, int64(1), stats.Encodings["deflate"].Responses, "Deflate responses not counted")
+	assert.Equal(t, map[string]int64{
+		SkipNotAccepted: 1,
+		SkipTooSmall:    1,
+		SkipContentType: 1,
+		SkipNoBody:      1,
+	}, stats.Skipped, "Skipped responses not counted")
+}
+
+//slowClient takes its time for every write like a client on a slow connection
+type slowClient struct {
+	*httptest.ResponseRecorder
+}
+
+func (c slowClient) Write(b []byte) (int, error) {
+	time.Sleep(20 * time.Millisecond)
+	return c.ResponseRecorder.Write(b)
+}
+
+func Test_Compressor_Stats_SlowClient(t *testing.T) {
+	c := testCompressor(t, DefaultCompressionOptions())
+	handler := c.Compression(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		for i := 0; i < 3; i++ {
+			fmt.Fprint(w, strings.Repeat("slow ", 1000))
+			w.(http.Flusher).Flush()
+		}
+	}))
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	handler.ServeHTTP(slowClient{httptest.NewRecorder()}, req)
+
+	encodeTime := c.Stats().Encodings["gzip"].EncodeTime
+	assert.True(t, encodeTime > 0, "Encode time not measured")
+	assert.True(t, encodeTime < 20*time.Millisecond, "Writes to the client counted as encode time: %v", encodeTime)
+}
+
+func Test_Monitor_CompressionStats(t *testing.T) {
+	m := NewMonitor()
+	defer m.Close(context.Background())
+	assert.Nil(t, m.Get().Compression, "Compression stats without compression")
+
+	body := strings.Repeat("monitored ", 100)
+	handler := m.Monitor(Compression(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, body)
+	})))
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+
+	data := m.Get()
+	if assert.NotNil(t, data.Compression, "Compression stats missing") {
+		assert.Equal(t, int64(1), data.Compression.Encodings["gzip"].Responses, "Compressed response not counted")
+		assert.Equal(t, int64(len(body)), data.Compression.Encodings["gzip"].BytesIn, "Uncompressed bytes not counted")
+		assert.Equal(t, int64(1), data.Compression.Skipped[SkipNotAccepted], "Skipped response not counted")
+	}
+	assert.Equal(t, int64(2*len(body)), data.TotalContentBytes, "Content bytes not counted")
+}
@@ -1,10 +1,15 @@
 package goat
 
 import (
+	"bytes"
 	"compress/gzip"
+	"errors"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -34,3 +39,219 @@ func Test_Compression(t *testing.T) {
 	b, _ := ioutil.ReadAll(reader)
 	assert.Equal(t, "this is compression test", string(b), "No gzip string doesnt match")
 }
+
+//testCompressor creates a Compressor, failing the test if the options are invalid
+func testCompressor(t *testing.T, options CompressionOptions) *Compressor {
+	c, err := NewCompressor(options)
+	assert.NoError(t, err)
+	return c
+}
+
+func Test_Compressor_MinSize(t *testing.T) {
+	options := CompressionOptions{MinSize: 100}
+	small := serve(testCompressor(t, options).Compression(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprint(w, "small")
+	})), "GET", "/", nil, http.Header{"Accept-Encoding": {"gzip"}})
+	assert.Equal(t, "", small.Header().Get("Content-Encoding"), "Small body compressed")
+	assert.Equal(t, http.StatusCreated, small.Code, "Status not kept")
+	assert.Equal(t, "small", small.Body.String(), "Body does not match")
+
+	large := strings.Repeat("large body ", 20)
+	compressed := serve(testCompressor(t, options).Compression(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", strconv.Itoa(len(large)))
+		for i := 0; i < 20; i++ {
+			fmt.Fprint(w, "large body ")
+		}
+	})), "GET", "/", nil, http.Header{"Accept-Encoding": {"gzip"}})
+	assert.Equal(t, "gzip", compressed.Header().Get("Content-Encoding"), "Large body not compressed")
+	assert.Equal(t, "", compressed.Header().Get("Content-Length"), "Content-Length not removed")
+	assert.Equal(t, "text/plain; charset=utf-8", compressed.Header().Get("Content-Type"), "Content-Type not sniffed")
+	reader, _ := gzip.NewReader(compressed.Body)
+	b, _ := ioutil.ReadAll(reader)
+	assert.Equal(t, large, string(b), "Body does not match")
+
+	announced := serve(testCompressor(t, options).Compression(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "5")
+		w.WriteHeader(http.StatusOK)
+		assert.Equal(t, "5", w.Header().Get("Content-Length"))
+		fmt.Fprint(w, "small")
+	})), "GET", "/", nil, http.Header{"Accept-Encoding": {"gzip"}})
+	assert.Equal(t, "", announced.Header().Get("Content-Encoding"), "Small Content-Length compressed")
+}
+
+func Test_Compressor_ContentTypes(t *testing.T) {
+	options := CompressionOptions{
+		ContentTypes:         []string{"text/*", "application/*+json"},
+		ExcludedContentTypes: []string{"text/csv"},
+	}
+	typed := func(contentType string) *httptest.ResponseRecorder {
+		return serve(testCompressor(t, options).Compression(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", contentType)
+			fmt.Fprint(w, "body")
+		})), "GET", "/", nil, http.Header{"Accept-Encoding": {"gzip"}})
+	}
+	assert.Equal(t, "gzip", typed("text/html; charset=utf-8").Header().Get("Content-Encoding"), "text/html not compressed")
+	assert.Equal(t, "gzip", typed("application/problem+json").Header().Get("Content-Encoding"), "Suffix pattern not matched")
+	assert.Equal(t, "", typed("text/csv").Header().Get("Content-Encoding"), "Excluded type compressed")
+	assert.Equal(t, "", typed("application/json").Header().Get("Content-Encoding"), "Type not listed compressed")
+	assert.Equal(t, "body", typed("application/json").Body.String(), "Body does not match")
+
+	png := serve(testCompressor(t, DefaultCompressionOptions()).Compression(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		fmt.Fprint(w, "png")
+	})), "GET", "/", nil, http.Header{"Accept-Encoding": {"gzip"}})
+	assert.Equal(t, "", png.Header().Get("Content-Encoding"), "Default preset compressed an image")
+
+	_, err := NewCompressor(CompressionOptions{ContentTypes: []string{"text/["}})
+	assert.Error(t, err, "Malformed pattern accepted")
+	_, err = NewCompressor(CompressionOptions{Level: LevelOf(42)})
+	var compressionErr *CompressionError
+	if assert.True(t, errors.As(err, &compressionErr), "Invalid level accepted") {
+		assert.Equal(t, "level", compressionErr.Option, "Option does not match")
+	}
+}
+
+func Test_Compressor_NoCompression(t *testing.T) {
+	body := strings.Repeat("stored, not compressed ", 100)
+	options := DefaultCompressionOptions()
+	options.Level = LevelOf(gzip.NoCompression)
+	rr := serve(testCompressor(t, options).Compression(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		fmt.Fprint(w, body)
+	})), "GET", "/", nil, http.Header{"Accept-Encoding": {"gzip"}})
+	assert.Equal(t, "gzip", rr.Header().Get("Content-Encoding"), "Response not encoded")
+	assert.True(t, rr.Body.Len() > len(body), "Level 0 compressed the body")
+}
+
+func Test_Compressor_AlreadyEncoded(t *testing.T) {
+	rr := serve(testCompressor(t, DefaultCompressionOptions()).Compression(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", "br")
+		fmt.Fprint(w, "brotli data")
+	})), "GET", "/", nil, http.Header{"Accept-Encoding": {"gzip"}})
+	assert.Equal(t, "br", rr.Header().Get("Content-Encoding"), "Content-Encoding replaced")
+	assert.Equal(t, "brotli data", rr.Body.String(), "Encoded body changed")
+}
+
+//statusWriter is a full featured writer which remembers every status written to it
+type statusWriter struct {
+	fullWriter
+	statuses []int
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	w.statuses = append(w.statuses, status)
+}
+
+func Test_Compression_StreamingFlush(t *testing.T) {
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	flushed := make(chan string, 1)
+	Compression(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		fmt.Fprint(w, "first event")
+		w.(http.Flusher).Flush()
+		//everything written so far has to be decodable before the handler is done
+		reader, err := gzip.NewReader(bytes.NewReader(rr.Body.Bytes()))
+		if assert.NoError(t, err) {
+			b := make([]byte, len("first event"))
+			io.ReadFull(reader, b)
+			flushed <- string(b)
+		}
+	})).ServeHTTP(rr, req)
+	assert.Equal(t, "first event", <-flushed, "Flush did not flush the encoder")
+	assert.True(t, rr.Flushed, "Recorder not flushed")
+}
+
+func Test_Compression_EventStreamExcluded(t *testing.T) {
+	rr := serve(testCompressor(t, DefaultCompressionOptions()).Compression(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprint(w, "data: ping\n\n")
+		w.(http.Flusher).Flush()
+	})), "GET", "/", nil, http.Header{"Accept-Encoding": {"gzip"}})
+	assert.Equal(t, "", rr.Header().Get("Content-Encoding"), "Event stream compressed")
+	assert.Equal(t, "data: ping\n\n", rr.Body.String(), "Event stream changed")
+}
+
+func Test_Compression_NoBody(t *testing.T) {
+	for _, status := range []int{http.StatusNoContent, http.StatusNotModified} {
+		rr := serve(testCompressor(t, DefaultCompressionOptions()).Compression(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(status)
+		})), "GET", "/", nil, http.Header{"Accept-Encoding": {"gzip"}})
+		assert.Equal(t, status, rr.Code, "Status does not match")
+		assert.Equal(t, "", rr.Header().Get("Content-Encoding"), "Response without body compressed")
+		assert.Equal(t, 0, rr.Body.Len(), "Body written for %d", status)
+	}
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest("HEAD", "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	Compression(&TestCompressionHandler{}).ServeHTTP(rr, req)
+	assert.Equal(t, "", rr.Header().Get("Content-Encoding"), "HEAD response compressed")
+}
+
+func Test_Compression_Informational(t *testing.T) {
+	w := &statusWriter{}
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	Compression(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Header().Set("Link", "</app.css>; rel=preload")
+		rw.WriteHeader(http.StatusEarlyHints)
+		rw.WriteHeader(http.StatusOK)
+		fmt.Fprint(rw, "body")
+	})).ServeHTTP(w, req)
+	assert.Equal(t, []int{http.StatusEarlyHints, http.StatusOK}, w.statuses, "Informational status not passed through")
+	assert.Equal(t, "gzip", w.Header().Get("Content-Encoding"), "Final response not compressed")
+}
+
+func Test_Compression_Hijack(t *testing.T) {
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	hijacked := false
+	Compression(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hijacker, ok := w.(http.Hijacker)
+		if assert.True(t, ok, "Hijacker not passed through") {
+			_, _, err := hijacker.Hijack()
+			hijacked = err == nil
+		}
+	})).ServeHTTP(&fullWriter{}, req)
+	assert.True(t, hijacked, "Connection not hijacked")
+
+	Compression(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, ok := w.(http.Hijacker)
+		assert.False(t, ok, "Hijacker claimed for a writer which can not hijack")
+	})).ServeHTTP(httptest.NewRecorder(), req)
+}
+
+func Test_Compression_KeepsInterfaces(t *testing.T) {
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	writers := map[string]http.ResponseWriter{
+		"plain":    &plainWriter{},
+		"recorder": httptest.NewRecorder(),
+		"full":     &fullWriter{},
+	}
+	for name, inner := range writers {
+		var got writerInterfaces
+		Compression(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			got = interfacesOf(w)
+		})).ServeHTTP(inner, req)
+		assert.Equal(t, interfacesOf(inner), got, "Interfaces of the %s writer not kept", name)
+	}
+}
+
+func Test_Compression_ReadFrom(t *testing.T) {
+	body := strings.Repeat("served from a file ", 100)
+	rr := serve(testCompressor(t, DefaultCompressionOptions()).Compression(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		io.Copy(w, strings.NewReader(body))
+	})), "GET", "/", nil, http.Header{"Accept-Encoding": {"gzip"}})
+	assert.Equal(t, "gzip", rr.Header().Get("Content-Encoding"), "Body copied with ReadFrom not compressed")
+	reader, err := gzip.NewReader(rr.Body)
+	if assert.NoError(t, err) {
+		b, _ := ioutil.ReadAll(reader)
+		assert.Equal(t, body, string(b), "Body does not match")
+	}
+}
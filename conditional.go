@@ -0,0 +1,191 @@
+package goat
+
+import (
+	"net/http"
+	"path"
+	"regexp"
+	"strings"
+)
+
+//RequestMatcher func decides whether a conditional middleware runs for a request
+type RequestMatcher func(r *http.Request) bool
+
+//PathPrefix matches requests whose url path starts with prefix
+func PathPrefix(prefix string) RequestMatcher {
+	return func(r *http.Request) bool {
+		return strings.HasPrefix(r.URL.Path, prefix)
+	}
+}
+
+//PathGlob matches requests whose url path matches the shell pattern, see path.Match for the syntax.
+//It panics if the pattern is malformed
+func PathGlob(pattern string) RequestMatcher {
+	if _, err := path.Match(pattern, ""); err != nil {
+		panic(err)
+	}
+	return func(r *http.Request) bool {
+		matched, _ := path.Match(pattern, r.URL.Path)
+		return matched
+	}
+}
+
+//PathRegexp matches requests whose url path matches the regular expression.
+//It panics if the expression does not compile
+func PathRegexp(expr string) RequestMatcher {
+	re := regexp.MustCompile(expr)
+	return func(r *http.Request) bool {
+		return re.MatchString(r.URL.Path)
+	}
+}
+
+//Method matches requests sent with any of the given http methods
+func Method(methods ...string) RequestMatcher {
+	return func(r *http.Request) bool {
+		for _, method := range methods {
+			if strings.EqualFold(r.Method, method) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+//HasHeader matches requests which carry the header, if values are given the header also has to be equal to one of them
+func HasHeader(name string, values ...string) RequestMatcher {
+	return func(r *http.Request) bool {
+		got, ok := r.Header[http.CanonicalHeaderKey(name)]
+		if !ok {
+			return false
+		}
+		if len(values) == 0 {
+			return true
+		}
+		for _, v := range got {
+			for _, value := range values {
+				if v == value {
+					return true
+				}
+			}
+		}
+		return false
+	}
+}
+
+//Not inverts a matcher
+func Not(match RequestMatcher) RequestMatcher {
+	return func(r *http.Request) bool {
+		return !match(r)
+	}
+}
+
+//Any matches requests matched by at least one of the matchers
+func Any(matchers ...RequestMatcher) RequestMatcher {
+	return func(r *http.Request) bool {
+		for _, match := range matchers {
+			if match(r) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+//All matches requests matched by every one of the matchers
+func All(matchers ...RequestMatcher) RequestMatcher {
+	return func(r *http.Request) bool {
+		for _, match := range matchers {
+			if !match(r) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+//When wraps a middleware so that it only runs for requests the matcher accepts,
+//other requests go straight to the next handler as if the middleware was not in the chain.
+//It is listed as When(name) with the name of the wrapped middleware and keeps its ordering constraints
+func When(match RequestMatcher, middleware Middleware) Middleware {
+	return conditional("When", match, middleware)
+}
+
+//Unless wraps a middleware so that it runs for every request except the ones the matcher accepts,
+//it is listed as Unless(name)
+func Unless(match RequestMatcher, middleware Middleware) Middleware {
+	return conditional("Unless", Not(match), middleware)
+}
+
+//conditionalProbe is passed to a conditional middleware to ask what it wraps, it answers with a conditionalInfo
+type conditionalProbe struct{}
+
+func (conditionalProbe) ServeHTTP(w http.ResponseWriter, r *http.Request) {}
+
+//conditionalInfo describes the middleware wrapped by When or Unless
+type conditionalInfo struct {
+	conditionalProbe
+	name      string
+	lifecycle Lifecycle
+}
+
+func conditional(kind string, match RequestMatcher, middleware Middleware) Middleware {
+	return func(next http.Handler) http.Handler {
+		if _, ok := next.(conditionalProbe); ok {
+			return conditionalInfo{
+				name:      kind + "(" + MiddlewareName(middleware) + ")",
+				lifecycle: lifecycleFor(middleware),
+			}
+		}
+		wrapped := middleware(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if match(r) {
+				wrapped.ServeHTTP(w, r)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+//conditionalPointer is the code pointer shared by every middleware returned from When and Unless
+var conditionalPointer uintptr
+
+func init() {
+	conditionalPointer = funcPointer(conditional("", nil, nil))
+}
+
+//describeConditional returns what a middleware returned from When or Unless wraps, without building a handler
+func describeConditional(middleware Middleware) (conditionalInfo, bool) {
+	if funcPointer(middleware) != conditionalPointer {
+		return conditionalInfo{}, false
+	}
+	info, ok := middleware(conditionalProbe{}).(conditionalInfo)
+	return info, ok
+}
+
+//unconditionalName strips the When and Unless wrappers from a name
+func unconditionalName(name string) string {
+	for _, kind := range []string{"When(", "Unless("} {
+		if strings.HasPrefix(name, kind) && strings.HasSuffix(name, ")") {
+			return unconditionalName(name[len(kind) : len(name)-1])
+		}
+	}
+	return name
+}
+
+//When func returns a new chain where every middleware only runs for requests the matcher accepts.
+//Names are kept so the new chain lists the same middlewares as the original one
+func (mc MiddlewareChain) When(match RequestMatcher) MiddlewareChain {
+	middlewares := make([]NamedMiddleware, len(mc.middlewares))
+	for i, m := range mc.middlewares {
+		m.Middleware = When(match, m.Middleware)
+		middlewares[i] = m
+	}
+	return MiddlewareChain{
+		middlewares: middlewares,
+	}
+}
+
+//Unless func returns a new chain where every middleware is skipped for requests the matcher accepts
+func (mc MiddlewareChain) Unless(match RequestMatcher) MiddlewareChain {
+	return mc.When(Not(match))
+}
@@ -0,0 +1,78 @@
+package goat
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_When_PathPrefix(t *testing.T) {
+	h := New(When(PathPrefix("/api"), NoCache)).Then(&TestNoCacheHandler{})
+
+	rr := serve(h, "GET", "/api/users", nil, nil)
+	assert.Equal(t, "no-cache", rr.Header().Get("Pragma"), "NoCache did not run for matching path")
+
+	rr = serve(h, "GET", "/static/app.js", nil, nil)
+	assert.Equal(t, "", rr.Header().Get("Pragma"), "NoCache ran for other path")
+	assert.Equal(t, http.StatusOK, rr.Code, "Status Code does not match")
+}
+
+func Test_Unless_Path(t *testing.T) {
+	h := New(Unless(PathGlob("/metrics*"), XSS)).ThenFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	rr := serve(h, "GET", "/metrics", nil, nil)
+	assert.Equal(t, "", rr.Header().Get("X-XSS-Protection"), "XSS ran for excluded path")
+
+	rr = serve(h, "GET", "/index", nil, nil)
+	assert.Equal(t, "1; mode=block", rr.Header().Get("X-XSS-Protection"), "XSS did not run")
+}
+
+func Test_Matchers(t *testing.T) {
+	req := httptest.NewRequest("POST", "/v2/orders/42", nil)
+	req.Header.Set("X-Debug", "on")
+
+	assert.True(t, PathRegexp(`^/v\d+/orders/\d+$`)(req), "PathRegexp did not match")
+	assert.False(t, PathRegexp(`^/v\d+/users`)(req), "PathRegexp matched")
+	assert.True(t, Method("GET", "post")(req), "Method did not match")
+	assert.False(t, Method("GET")(req), "Method matched")
+	assert.True(t, HasHeader("x-debug")(req), "HasHeader did not match")
+	assert.True(t, HasHeader("X-Debug", "on")(req), "HasHeader value did not match")
+	assert.False(t, HasHeader("X-Debug", "off")(req), "HasHeader value matched")
+	assert.True(t, All(Method("POST"), PathPrefix("/v2"))(req), "All did not match")
+	assert.False(t, All(Method("POST"), PathPrefix("/v1"))(req), "All matched")
+	assert.True(t, Any(Method("GET"), PathPrefix("/v2"))(req), "Any did not match")
+	assert.Panics(t, func() { PathGlob("[") }, "Malformed glob accepted")
+}
+
+func Test_Chain_When(t *testing.T) {
+	custom := func(r *http.Request) bool { return r.URL.Query().Get("secure") == "1" }
+	mc := New(NoCache, XSS).When(custom)
+	assert.Equal(t, []string{"NoCache", "XSS"}, mc.Names(), "Names not kept")
+
+	h := mc.ThenFunc(func(w http.ResponseWriter, r *http.Request) {})
+	rr := serve(h, "GET", "/?secure=1", nil, nil)
+	assert.Equal(t, "1; mode=block", rr.Header().Get("X-XSS-Protection"), "Chain did not run for matching request")
+	assert.Equal(t, "no-cache", rr.Header().Get("Pragma"), "Chain did not run for matching request")
+
+	rr = serve(h, "GET", "/", nil, nil)
+	assert.Equal(t, "", rr.Header().Get("X-XSS-Protection"), "Chain ran for other request")
+	assert.Equal(t, "", rr.Header().Get("Pragma"), "Chain ran for other request")
+}
+
+func Test_When_Names(t *testing.T) {
+	api := PathPrefix("/api")
+	mc := New(When(api, Recovery), Unless(api, XSS), When(api, Unless(Method("GET"), NoCache)), Logger)
+	assert.Equal(t, []string{"When(Recovery)", "Unless(XSS)", "When(Unless(NoCache))", "Logger"}, mc.Names(), "Conditional names do not match")
+
+	list := New(When(api, RecoverAndLogPanic)).List()
+	assert.Equal(t, defaultPanicLogger, list[0].Lifecycle, "Lifecycle of the wrapped middleware not kept")
+
+	err := New(Logger, When(api, Recovery)).Validate()
+	var orderErr *OrderError
+	if assert.True(t, errors.As(err, &orderErr), "Conditional Recovery not constrained") {
+		assert.Equal(t, []OrderViolation{{First: "When(Recovery)", Second: "Logger", Reason: "When(Recovery) declares Before Logger"}}, orderErr.Violations)
+	}
+}
@@ -0,0 +1,190 @@
+package goat
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+//Constraint struct declares where a named middleware has to sit in a MiddlewareChain.
+//A middleware that runs before another one wraps it, i.e. it sees the request first and the response last
+type Constraint struct {
+	Before    []string //names of the middlewares this one has to run before
+	After     []string //names of the middlewares this one has to run after
+	Outermost bool     //run before every other middleware, except the ones that explicitly declare to run before this one
+}
+
+var constraints = struct {
+	mu    sync.RWMutex
+	rules map[string]Constraint
+}{
+	rules: map[string]Constraint{},
+}
+
+func init() {
+	//the recovery middlewares wrap the middlewares that do real work around the handler,
+	//so a panic in Logger, Monitor or Compression is recovered too. Logger and Monitor see a panic
+	//pass through them and report it with 500, the status the recovery middlewares answer with
+	wrapped := []string{"Logger", "Monitor", "Compression"}
+	DeclareConstraint("Recovery", Constraint{Before: wrapped})
+	DeclareConstraint("RecoverAndLogPanic", Constraint{Before: wrapped})
+	//Compression wraps the middlewares which write a body of their own, so their responses get encoded too.
+	//The error pages of the recovery middlewares are the exception, they are written outside Compression
+	DeclareConstraint("Compression", Constraint{Before: []string{"Decompression"}})
+}
+
+//DeclareConstraint func registers the ordering constraint for the middleware with the given name,
+//a later declaration for the same name replaces the earlier one
+func DeclareConstraint(name string, constraint Constraint) {
+	constraints.mu.Lock()
+	defer constraints.mu.Unlock()
+	constraints.rules[name] = constraint
+}
+
+//ConstraintFor func returns the ordering constraint declared for the middleware with the given name
+func ConstraintFor(name string) (Constraint, bool) {
+	constraints.mu.RLock()
+	defer constraints.mu.RUnlock()
+	constraint, ok := constraints.rules[name]
+	return constraint, ok
+}
+
+//OrderViolation describes a single broken ordering constraint
+type OrderViolation struct {
+	First  string //middleware that has to run first
+	Second string //middleware that has to run after First
+	Reason string //the constraint which requires the order
+}
+
+//OrderError is returned when the middlewares of a chain break their ordering constraints
+type OrderError struct {
+	Violations []OrderViolation //constraints broken by the order of the chain
+	Cycle      []string         //middlewares whose constraints contradict each other, no order can satisfy them
+}
+
+func (e *OrderError) Error() string {
+	if len(e.Cycle) != 0 {
+		return "middleware ordering constraints form a cycle between " + strings.Join(e.Cycle, ", ")
+	}
+	var messages []string
+	for _, v := range e.Violations {
+		messages = append(messages, fmt.Sprintf("%s must run before %s (%s)", v.First, v.Second, v.Reason))
+	}
+	return "invalid middleware order: " + strings.Join(messages, "; ")
+}
+
+//orderEdge says the middleware at position from has to run before the middleware at position to
+type orderEdge struct {
+	from, to int
+	reason   string
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+//edges turns the constraints of the middlewares in the chain into edges between their positions.
+//Middlewares wrapped by When or Unless keep the constraints of the middleware they wrap
+func (mc MiddlewareChain) edges() []orderEdge {
+	names := make([]string, len(mc.middlewares))
+	rules := make([]Constraint, len(mc.middlewares))
+	for i, m := range mc.middlewares {
+		names[i] = unconditionalName(m.Name)
+		rules[i], _ = ConstraintFor(names[i])
+	}
+	runsBefore := func(i, j int) bool {
+		return contains(rules[i].Before, names[j]) || contains(rules[j].After, names[i])
+	}
+
+	var edges []orderEdge
+	for i, m := range mc.middlewares {
+		for j, other := range mc.middlewares {
+			if i == j || names[i] == names[j] {
+				continue
+			}
+			switch {
+			case contains(rules[i].Before, names[j]):
+				edges = append(edges, orderEdge{i, j, fmt.Sprintf("%s declares Before %s", m.Name, other.Name)})
+			case contains(rules[i].After, names[j]):
+				edges = append(edges, orderEdge{j, i, fmt.Sprintf("%s declares After %s", m.Name, other.Name)})
+			case rules[i].Outermost && !rules[j].Outermost && !runsBefore(j, i):
+				edges = append(edges, orderEdge{i, j, fmt.Sprintf("%s declares Outermost", m.Name)})
+			}
+		}
+	}
+	return edges
+}
+
+//Validate func checks the order of the chain against the declared constraints and returns an *OrderError naming every conflict
+func (mc MiddlewareChain) Validate() error {
+	var violations []OrderViolation
+	for _, e := range mc.edges() {
+		if e.from > e.to {
+			violations = append(violations, OrderViolation{
+				First:  mc.middlewares[e.from].Name,
+				Second: mc.middlewares[e.to].Name,
+				Reason: e.reason,
+			})
+		}
+	}
+	if len(violations) != 0 {
+		return &OrderError{Violations: violations}
+	}
+	return nil
+}
+
+//Reorder func returns a new chain that satisfies the declared constraints.
+//Middlewares keep their relative order wherever the constraints allow it, an *OrderError is returned if the constraints contradict each other
+func (mc MiddlewareChain) Reorder() (MiddlewareChain, error) {
+	n := len(mc.middlewares)
+	incoming := make([]int, n)
+	outgoing := make([][]int, n)
+	for _, e := range mc.edges() {
+		incoming[e.to]++
+		outgoing[e.from] = append(outgoing[e.from], e.to)
+	}
+
+	placed := make([]bool, n)
+	ordered := make([]NamedMiddleware, 0, n)
+	for len(ordered) < n {
+		//always take the earliest middleware that is free to go, so valid parts of the chain keep their order
+		next := -1
+		for i := 0; i < n; i++ {
+			if !placed[i] && incoming[i] == 0 {
+				next = i
+				break
+			}
+		}
+		if next < 0 {
+			var cycle []string
+			for i := 0; i < n; i++ {
+				if !placed[i] {
+					cycle = append(cycle, mc.middlewares[i].Name)
+				}
+			}
+			return mc, &OrderError{Cycle: cycle}
+		}
+		placed[next] = true
+		ordered = append(ordered, mc.middlewares[next])
+		for _, to := range outgoing[next] {
+			incoming[to]--
+		}
+	}
+	return MiddlewareChain{
+		middlewares: ordered,
+	}, nil
+}
+
+//ThenStrict func is similar to Then but refuses to build the handler if the chain breaks the declared ordering constraints
+func (mc MiddlewareChain) ThenStrict(handler http.Handler) (http.Handler, error) {
+	if err := mc.Validate(); err != nil {
+		return nil, err
+	}
+	return mc.Then(handler), nil
+}
@@ -0,0 +1,89 @@
+package goat
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Validate_CommonMiddlewares(t *testing.T) {
+	assert.NoError(t, CommonMiddlewares().Validate(), "Common middlewares break their own constraints")
+
+	h, err := CommonMiddlewares().ThenStrict(&TestNoCacheHandler{})
+	assert.NoError(t, err, "ThenStrict rejected a valid chain")
+	assert.NotNil(t, h, "ThenStrict did not build the handler")
+}
+
+func Test_Validate_Violation(t *testing.T) {
+	mc := New(Logger, Compression, Recovery)
+	h, err := mc.ThenStrict(&TestNoCacheHandler{})
+	assert.Nil(t, h, "ThenStrict built an invalid chain")
+
+	var orderErr *OrderError
+	if assert.True(t, errors.As(err, &orderErr), "Error is not an OrderError") {
+		assert.Contains(t, orderErr.Violations, OrderViolation{First: "Recovery", Second: "Logger", Reason: "Recovery declares Before Logger"}, "Logger violation not reported")
+		assert.Contains(t, orderErr.Violations, OrderViolation{First: "Recovery", Second: "Compression", Reason: "Recovery declares Before Compression"}, "Compression violation not reported")
+	}
+	assert.Contains(t, err.Error(), "Recovery must run before Logger", "Error does not name the conflicting middlewares")
+}
+
+func Test_Validate_Compression(t *testing.T) {
+	err := New(Recovery, Decompression, Compression).Validate()
+	var orderErr *OrderError
+	if assert.True(t, errors.As(err, &orderErr), "Error is not an OrderError") {
+		assert.Equal(t, []OrderViolation{{First: "Compression", Second: "Decompression", Reason: "Compression declares Before Decompression"}}, orderErr.Violations, "Compression violation not reported")
+	}
+	assert.NoError(t, New(Recovery, Logger, Compression, Decompression).Validate(), "Valid chain rejected")
+}
+
+func Test_Validate_Outermost(t *testing.T) {
+	declareTestConstraint(t, "Outer", Constraint{Outermost: true})
+	outer := Named("Outer", func(next http.Handler) http.Handler { return next })
+
+	err := NewNamed(Named("NoCache", NoCache), outer).Validate()
+	var orderErr *OrderError
+	if assert.True(t, errors.As(err, &orderErr), "Error is not an OrderError") {
+		assert.Equal(t, []OrderViolation{{First: "Outer", Second: "NoCache", Reason: "Outer declares Outermost"}}, orderErr.Violations, "Outermost violation not reported")
+	}
+
+	mc, err := NewNamed(Named("NoCache", NoCache), outer).Reorder()
+	assert.NoError(t, err, "Reorder failed")
+	assert.Equal(t, []string{"Outer", "NoCache"}, mc.Names(), "Outermost middleware not moved to the front")
+}
+
+func Test_Reorder(t *testing.T) {
+	mc, err := New(NoCache, Logger, Compression, Recovery).Reorder()
+	assert.NoError(t, err, "Reorder failed")
+	assert.Equal(t, []string{"NoCache", "Recovery", "Logger", "Compression"}, mc.Names(), "Reordered chain does not match")
+	assert.NoError(t, mc.Validate(), "Reordered chain is not valid")
+
+	valid := New(XSS, NoCache)
+	reordered, err := valid.Reorder()
+	assert.NoError(t, err, "Reorder failed")
+	assert.Equal(t, valid.Names(), reordered.Names(), "Unconstrained chain changed order")
+}
+
+func Test_Reorder_Cycle(t *testing.T) {
+	first := Named("First", func(next http.Handler) http.Handler { return next })
+	second := Named("Second", func(next http.Handler) http.Handler { return next })
+	declareTestConstraint(t, "First", Constraint{After: []string{"Second"}})
+	declareTestConstraint(t, "Second", Constraint{After: []string{"First"}})
+
+	_, err := NewNamed(first, second).Reorder()
+	var orderErr *OrderError
+	if assert.True(t, errors.As(err, &orderErr), "Error is not an OrderError") {
+		assert.Equal(t, []string{"First", "Second"}, orderErr.Cycle, "Cycle does not match")
+	}
+}
+
+//declareTestConstraint declares a constraint for the duration of the test only
+func declareTestConstraint(t *testing.T, name string, constraint Constraint) {
+	DeclareConstraint(name, constraint)
+	t.Cleanup(func() {
+		constraints.mu.Lock()
+		defer constraints.mu.Unlock()
+		delete(constraints.rules, name)
+	})
+}
@@ -0,0 +1,179 @@
+package goat
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net"
+	"net/http"
+	"sync"
+)
+
+type storeContextKey struct{}
+
+//Store is a request scoped store attached to the request context, middlewares use it to hand data to each other.
+//Values set by an inner middleware are visible to the outer ones once the inner middleware returns
+type Store struct {
+	mu     sync.RWMutex
+	values map[interface{}]interface{}
+}
+
+//Key is a typed key for values in the Store, keys are compared by identity so create each one once with NewKey
+type Key[T any] struct {
+	name string
+}
+
+//NewKey func creates a key for values of type T, the name is only used for debugging
+func NewKey[T any](name string) *Key[T] {
+	return &Key[T]{name: name}
+}
+
+func (k *Key[T]) String() string {
+	return k.name
+}
+
+//well known keys used by the built-in middlewares
+var (
+	RequestIDKey    = NewKey[string]("request-id")
+	ClientIPKey     = NewKey[string]("client-ip")
+	UserKey         = NewKey[interface{}]("user")
+	RoutePatternKey = NewKey[string]("route-pattern")
+	CSPNonceKey     = NewKey[string]("csp-nonce")
+)
+
+//StoreFrom func returns the store attached to the context, or nil if there is none
+func StoreFrom(ctx context.Context) *Store {
+	store, _ := ctx.Value(storeContextKey{}).(*Store)
+	return store
+}
+
+//WithStore func returns the request with a store attached, the request is returned unchanged if it already has one.
+//Middlewares that want to see what inner middlewares set call it before they call next
+func WithStore(r *http.Request) *http.Request {
+	if StoreFrom(r.Context()) != nil {
+		return r
+	}
+	store := &Store{
+		values: map[interface{}]interface{}{},
+	}
+	return r.WithContext(context.WithValue(r.Context(), storeContextKey{}, store))
+}
+
+//ContextStore middleware attaches a store to the request, put it in front of the middlewares that share data
+func ContextStore(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, WithStore(r))
+	})
+}
+
+//Get func returns the value stored under the key
+func Get[T any](r *http.Request, key *Key[T]) (T, bool) {
+	var zero T
+	store := StoreFrom(r.Context())
+	if store == nil {
+		return zero, false
+	}
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+	value, ok := store.values[key].(T)
+	if !ok {
+		return zero, false
+	}
+	return value, true
+}
+
+//Set func stores the value under the key. It attaches a store if the request has none yet,
+//so always continue with the returned request
+func Set[T any](r *http.Request, key *Key[T], value T) *http.Request {
+	r = WithStore(r)
+	store := StoreFrom(r.Context())
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	store.values[key] = value
+	return r
+}
+
+//RequestID func returns the id of the request set by AssignRequestID
+func RequestID(r *http.Request) string {
+	id, _ := Get(r, RequestIDKey)
+	return id
+}
+
+//SetRequestID func stores the id of the request
+func SetRequestID(r *http.Request, id string) *http.Request {
+	return Set(r, RequestIDKey, id)
+}
+
+//ClientIP func returns the client ip stored for the request, or the host of RemoteAddr if none was stored
+func ClientIP(r *http.Request) string {
+	if ip, ok := Get(r, ClientIPKey); ok {
+		return ip
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+//SetClientIP func stores the client ip, e.g. after resolving it from a trusted proxy header
+func SetClientIP(r *http.Request, ip string) *http.Request {
+	return Set(r, ClientIPKey, ip)
+}
+
+//User func returns the authenticated user stored for the request
+func User(r *http.Request) interface{} {
+	user, _ := Get(r, UserKey)
+	return user
+}
+
+//SetUser func stores the authenticated user
+func SetUser(r *http.Request, user interface{}) *http.Request {
+	return Set(r, UserKey, user)
+}
+
+//RoutePattern func returns the pattern of the route that matched the request, set by Group
+func RoutePattern(r *http.Request) string {
+	pattern, _ := Get(r, RoutePatternKey)
+	return pattern
+}
+
+//SetRoutePattern func stores the pattern of the route that matched the request
+func SetRoutePattern(r *http.Request, pattern string) *http.Request {
+	return Set(r, RoutePatternKey, pattern)
+}
+
+//CSPNonce func returns the nonce of the Content-Security-Policy sent with the response
+func CSPNonce(r *http.Request) string {
+	nonce, _ := Get(r, CSPNonceKey)
+	return nonce
+}
+
+//SetCSPNonce func stores the nonce of the Content-Security-Policy
+func SetCSPNonce(r *http.Request, nonce string) *http.Request {
+	return Set(r, CSPNonceKey, nonce)
+}
+
+//requestIDHeader is the header AssignRequestID reads and writes
+const requestIDHeader = "X-Request-ID"
+
+//AssignRequestID middleware stores an id for every request and sends it back in the X-Request-ID header.
+//An id sent by the client or a proxy in the same header is kept
+func AssignRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestIDHeader)
+		if id == "" || len(id) > 128 {
+			id = newRequestID()
+		}
+		w.Header().Set(requestIDHeader, id)
+		next.ServeHTTP(w, SetRequestID(r, id))
+	})
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,114 @@
+package goat
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Store_GetSet(t *testing.T) {
+	key := NewKey[int]("answer")
+	req := httptest.NewRequest("GET", "/", nil)
+
+	_, ok := Get(req, key)
+	assert.False(t, ok, "Value found without a store")
+
+	withValue := Set(req, key, 42)
+	value, ok := Get(withValue, key)
+	assert.True(t, ok, "Value not found")
+	assert.Equal(t, 42, value, "Value does not match")
+
+	//a request with a store is returned as is and shares the store
+	assert.Equal(t, withValue, Set(withValue, key, 43), "Store not reused")
+	value, _ = Get(withValue, key)
+	assert.Equal(t, 43, value, "Value not replaced")
+
+	other := NewKey[int]("answer")
+	_, ok = Get(withValue, other)
+	assert.False(t, ok, "Keys with the same name must not collide")
+	assert.Equal(t, "answer", key.String(), "Key name does not match")
+}
+
+func Test_Store_InnerValuesVisibleOutside(t *testing.T) {
+	var seen string
+	outer := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			r = WithStore(r)
+			next.ServeHTTP(w, r)
+			seen = User(r).(string)
+		})
+	}
+	auth := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, SetUser(r, "alice"))
+		})
+	}
+	handler := New(outer, auth).ThenFunc(func(w http.ResponseWriter, r *http.Request) {})
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	assert.Equal(t, "alice", seen, "User set by the inner middleware not visible")
+}
+
+func Test_Store_WellKnownKeys(t *testing.T) {
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "10.0.0.1:4321"
+	assert.Equal(t, "10.0.0.1", ClientIP(req), "Client ip must fall back to RemoteAddr")
+	assert.Equal(t, "", RequestID(req), "Request id must be empty")
+	assert.Nil(t, User(req), "User must be nil")
+
+	req = SetClientIP(req, "192.168.1.1")
+	req = SetRequestID(req, "abc")
+	req = SetRoutePattern(req, "GET /users/{id}")
+	req = SetCSPNonce(req, "nonce")
+	assert.Equal(t, "192.168.1.1", ClientIP(req), "Client ip does not match")
+	assert.Equal(t, "abc", RequestID(req), "Request id does not match")
+	assert.Equal(t, "GET /users/{id}", RoutePattern(req), "Route pattern does not match")
+	assert.Equal(t, "nonce", CSPNonce(req), "CSP nonce does not match")
+}
+
+func Test_AssignRequestID(t *testing.T) {
+	var id string
+	handler := New(AssignRequestID).ThenFunc(func(w http.ResponseWriter, r *http.Request) {
+		id = RequestID(r)
+	})
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "/", nil))
+	assert.Len(t, id, 32, "Request id not generated")
+	assert.Equal(t, id, rr.Header().Get("X-Request-ID"), "Request id header does not match")
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-Request-ID", "from-proxy")
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	assert.Equal(t, "from-proxy", id, "Incoming request id not kept")
+	assert.Equal(t, "from-proxy", rr.Header().Get("X-Request-ID"), "Request id header does not match")
+}
+
+func Test_Logger_RequestID(t *testing.T) {
+	buf := &bytes.Buffer{}
+	log.SetOutput(buf)
+	defer log.SetOutput(os.Stderr)
+
+	handler := New(Logger, AssignRequestID).ThenFunc(func(w http.ResponseWriter, r *http.Request) {})
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-Request-ID", "req-1")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	assert.True(t, strings.Contains(buf.String(), "| req-1"), "Request id not logged")
+}
+
+func Test_Group_RoutePattern(t *testing.T) {
+	var pattern string
+	mux := http.NewServeMux()
+	api := NewGroup(mux, "/api", New())
+	api.HandleFunc("GET /users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		pattern = RoutePattern(r)
+	})
+	serve(mux, "GET", "/api/users/7", nil, nil)
+	assert.Equal(t, "GET /api/users/{id}", pattern, "Route pattern does not match")
+}
@@ -1,176 +1,465 @@
 package goat
 
 import (
-	"bytes"
-	"html"
-	"html/template"
+	"fmt"
+	"io/fs"
 	"net/http"
+	"sort"
 	"strings"
 )
 
 //CSPOptions struct for getting all csp options , more info @ https://content-security-policy.com/
 type CSPOptions struct {
-	DefaultSrc      []string //The default-src is the default policy for loading content such as JavaScript, Images, CSS, Font's, AJAX requests, Frames, HTML5 Media
-	ScriptSrc       []string //Defines valid sources of JavaScript.
-	StyleSrc        []string //Defines valid sources of stylesheets.
-	ImgSrc          []string //Defines valid sources of images.
-	ConnectSrc      []string //Applies to XMLHttpRequest (AJAX), WebSocket or EventSource. If not allowed the browser emulates a 400 HTTP status code.
-	FontSrc         []string //	Defines valid sources of fonts.
-	ObjectSrc       []string //Defines valid sources of plugins, eg <object>, <embed> or <applet>.
-	MediaSrc        []string //Defines valid sources of audio and video, eg HTML5 <audio>, <video> elements.
-	ChildSrc        []string //Defines valid sources for web workers and nested browsing contexts loaded using elements such as <frame> and <iframe>
-	Sandbox         []string //Enables a sandbox for the requested resource similar to the iframe sandbox attribute. The sandbox applies a same origin policy, prevents popups, plugins and script execution is blocked. You can keep the sandbox value empty to keep all restrictions in place, or add values: allow-forms allow-same-origin allow-scripts allow-popups, allow-modals, allow-orientation-lock, allow-pointer-lock, allow-presentation, allow-popups-to-escape-sandbox, and allow-top-navigation
-	ReportURI       string   //Instructs the browser to POST reports of policy failures to this URI. You can also append -Report-Only to the HTTP header name to instruct the browser to only send reports (does not block anything).
-	FormAction      []string //Defines valid sources that can be used as a HTML <form> action.
-	FrameAncestors  []string //Defines valid sources for embedding the resource using <frame> <iframe> <object> <embed> <applet>. Setting this directive to 'none' should be roughly equivalent to X-Frame-Options: DENY
-	PluginTypes     []string //Defines valid MIME types for plugins invoked via <object> and <embed>. To load an <applet> you must specify application/x-java-applet.
-	IsHeaderCreated bool
-	HeaderString    string
-	HeaderStrings   []string
-	IsReportOnly    bool //send  Content-Security-Policy-Report-Only header
+	DefaultSrc     []string //The default-src is the default policy for loading content such as JavaScript, Images, CSS, Font's, AJAX requests, Frames, HTML5 Media
+	ScriptSrc      []string //Defines valid sources of JavaScript.
+	StyleSrc       []string //Defines valid sources of stylesheets.
+	ImgSrc         []string //Defines valid sources of images.
+	ConnectSrc     []string //Applies to XMLHttpRequest (AJAX), WebSocket or EventSource. If not allowed the browser emulates a 400 HTTP status code.
+	FontSrc        []string //	Defines valid sources of fonts.
+	ObjectSrc      []string //Defines valid sources of plugins, eg <object>, <embed> or <applet>.
+	MediaSrc       []string //Defines valid sources of audio and video, eg HTML5 <audio>, <video> elements.
+	ChildSrc       []string //Defines valid sources for web workers and nested browsing contexts loaded using elements such as <frame> and <iframe>
+	Sandbox        []string //Enables a sandbox for the requested resource similar to the iframe sandbox attribute. The sandbox applies a same origin policy, prevents popups, plugins and script execution is blocked. You can keep the sandbox value empty to keep all restrictions in place, or add values: allow-forms allow-same-origin allow-scripts allow-popups, allow-modals, allow-orientation-lock, allow-pointer-lock, allow-presentation, allow-popups-to-escape-sandbox, and allow-top-navigation
+	ReportURI      string   //Instructs the browser to POST reports of policy failures to this URI. You can also append -Report-Only to the HTTP header name to instruct the browser to only send reports (does not block anything).
+	FormAction     []string //Defines valid sources that can be used as a HTML <form> action.
+	FrameAncestors []string //Defines valid sources for embedding the resource using <frame> <iframe> <object> <embed> <applet>. Setting this directive to 'none' should be roughly equivalent to X-Frame-Options: DENY
+	//Deprecated: browsers dropped plugin-types, it is no longer sent. Block plugins with ObjectSrc 'none'
+	PluginTypes  []string
+	IsReportOnly bool //send  Content-Security-Policy-Report-Only header, NewCSP fails without a ReportURI or ReportTo
+	//CSP Level 3 directives
+	BaseURI                 []string //Restricts the URLs which can be used in the <base> element of a document.
+	WorkerSrc               []string //Defines valid sources of Worker, SharedWorker and ServiceWorker scripts.
+	ManifestSrc             []string //Defines valid sources of web app manifests.
+	FrameSrc                []string //Defines valid sources of nested browsing contexts loaded using elements such as <frame> and <iframe>.
+	ScriptSrcElem           []string //Defines valid sources of <script> elements, script-src is used if empty.
+	ScriptSrcAttr           []string //Defines valid sources of inline event handlers like onclick, script-src is used if empty.
+	StyleSrcElem            []string //Defines valid sources of <style> elements and stylesheet <link> elements, style-src is used if empty.
+	StyleSrcAttr            []string //Defines valid sources of inline style attributes, style-src is used if empty.
+	UpgradeInsecureRequests bool     //Makes the browser load http URLs of the page over https.
+	BlockAllMixedContent    bool     //Blocks http resources on https pages, superseded by upgrade-insecure-requests in current browsers.
+	RequireTrustedTypesFor  []string //Requires Trusted Types for DOM XSS sinks, the only value is 'script'.
+	TrustedTypes            []string //Names the Trusted Types policies the page may create, plus 'none', 'allow-duplicates' or *.
+	ReportTo                string   //Names the Reporting API group, declared in the Reporting-Endpoints header, which receives the reports.
+	//Directives holds directives without a typed field, keyed by name, so new directives never wait for goat.
+	//They are sent after the typed ones sorted by name, an empty list sends the directive without values
+	Directives map[string][]string
+	//Nonce adds a random 'nonce-…' source to NonceDirectives on every request, read it with CSPNonce or the template helpers
+	Nonce bool
+	//NonceDirectives receive the nonce, script-src, style-src and the -elem ones which are set if empty. Only script-src, style-src and
+	//their -elem directives take nonces. A directive which is not set starts from the DefaultSrc sources, an -elem one from its parent directive
+	NonceDirectives []string
+	//HashAlgorithm is sha256, sha384 or sha512, it hashes the inline contents below. sha256 if empty
+	HashAlgorithm string
+	//InlineScripts and InlineStyles are contents of inline <script> and <style> elements, script-src and style-src allow them by hash
+	InlineScripts []string
+	InlineStyles  []string
+	//ScriptFiles and StyleFiles are glob patterns of files in HashFS whose contents are allowed by hash like InlineScripts and InlineStyles
+	HashFS      fs.FS
+	ScriptFiles []string
+	StyleFiles  []string
+	//AuditInline, if set, receives the inline scripts and styles of buffered HTML responses which neither a hash nor the nonce allows
+	AuditInline func(r *http.Request, missing []InlineViolation)
 }
 
-type CSPHandler struct {
-	cspOptions CSPOptions
+//CSPError is returned by NewCSP for a policy which can not be sent as it is
+type CSPError struct {
+	//Policy names the policy of a CSPPolicySet, like report-only[1], it is empty for NewCSP
+	Policy    string
+	Directive string
+	Message   string
 }
 
-// var m = map[string]string{
-// 	"defaultSrc": defaultTemplate,
-// 	"scriptSrc":  scriptTemplate,
-// 	"styleSrc":   styleTemplate,
-// }
-
-func getTemplate(name string) string {
-	return name + " {{.}}"
+func (e *CSPError) Error() string {
+	if e.Policy != "" {
+		return "goat: csp " + e.Policy + " " + e.Directive + ": " + e.Message
+	}
+	return "goat: csp " + e.Directive + ": " + e.Message
 }
 
-func createStringFromValues(name string, values []string) string {
-	t := template.New(name)
-	tem := template.Must(t.Parse(getTemplate(name)))
-	s := strings.Join(values, " ")
+//CSPHandler struct sends a policy which is validated and serialized once by NewCSP, it is safe for concurrent use
+type CSPHandler struct {
+	headerName  string
+	headerValue string
+	//nonceParts is the policy split where the nonce of the request goes, nil without Nonce
+	nonceParts []string
+	//hashAlgorithm, allowedHashes and audit serve the audit of inline scripts and styles
+	hashAlgorithm string
+	allowedHashes map[string]bool
+	audit         func(r *http.Request, missing []InlineViolation)
+}
 
-	buf := &bytes.Buffer{}
-	tem.Execute(buf, s)
+//keywords of CSP source lists, with the single quotes the header needs
+const (
+	CSPSelf           = "'self'"
+	CSPNone           = "'none'"
+	CSPUnsafeInline   = "'unsafe-inline'"
+	CSPUnsafeEval     = "'unsafe-eval'"
+	CSPUnsafeHashes   = "'unsafe-hashes'"
+	CSPStrictDynamic  = "'strict-dynamic'"
+	CSPReportSample   = "'report-sample'"
+	CSPWasmUnsafeEval = "'wasm-unsafe-eval'"
+	//CSPScript is the value of require-trusted-types-for
+	CSPScript = "'script'"
+)
 
-	str := html.UnescapeString(buf.String())
-	return str
+//cspKeywords are the source keywords which only work in single quotes
+var cspKeywords = map[string]bool{
+	"self":             true,
+	"none":             true,
+	"unsafe-inline":    true,
+	"unsafe-eval":      true,
+	"unsafe-hashes":    true,
+	"strict-dynamic":   true,
+	"report-sample":    true,
+	"wasm-unsafe-eval": true,
 }
 
-func createStringFromValue(name string, value string) string {
-	t := template.New(name)
-	tem := template.Must(t.Parse(getTemplate(name)))
-
-	buf := &bytes.Buffer{}
-	tem.Execute(buf, value)
+//sandboxTokens are the flags the sandbox directive accepts
+var sandboxTokens = map[string]bool{
+	"allow-downloads":                          true,
+	"allow-forms":                              true,
+	"allow-modals":                             true,
+	"allow-orientation-lock":                   true,
+	"allow-pointer-lock":                       true,
+	"allow-popups":                             true,
+	"allow-popups-to-escape-sandbox":           true,
+	"allow-presentation":                       true,
+	"allow-same-origin":                        true,
+	"allow-scripts":                            true,
+	"allow-storage-access-by-user-activation":  true,
+	"allow-top-navigation":                     true,
+	"allow-top-navigation-by-user-activation":  true,
+	"allow-top-navigation-to-custom-protocols": true,
+}
 
-	str := html.UnescapeString(buf.String())
-	return str
+//nonceCapable are the directives a nonce can be added to
+var nonceCapable = map[string]bool{
+	"script-src":      true,
+	"script-src-elem": true,
+	"style-src":       true,
+	"style-src-elem":  true,
 }
 
-func NewCSP(cspOptions CSPOptions) *CSPHandler {
-	csp := &CSPHandler{
-		cspOptions: cspOptions,
+//NewCSP func validates the options and builds the header once, invalid values like a source containing
+//a semicolon or an unquoted keyword are reported as a CSPError
+func NewCSP(cspOptions CSPOptions) (*CSPHandler, error) {
+	directives := []struct {
+		name   string
+		values []string
+	}{
+		{"default-src", cspOptions.DefaultSrc},
+		{"script-src", cspOptions.ScriptSrc},
+		{"script-src-elem", cspOptions.ScriptSrcElem},
+		{"script-src-attr", cspOptions.ScriptSrcAttr},
+		{"style-src", cspOptions.StyleSrc},
+		{"style-src-elem", cspOptions.StyleSrcElem},
+		{"style-src-attr", cspOptions.StyleSrcAttr},
+		{"img-src", cspOptions.ImgSrc},
+		{"connect-src", cspOptions.ConnectSrc},
+		{"font-src", cspOptions.FontSrc},
+		{"object-src", cspOptions.ObjectSrc},
+		{"media-src", cspOptions.MediaSrc},
+		{"child-src", cspOptions.ChildSrc},
+		{"frame-src", cspOptions.FrameSrc},
+		{"worker-src", cspOptions.WorkerSrc},
+		{"manifest-src", cspOptions.ManifestSrc},
+		{"base-uri", cspOptions.BaseURI},
+		{"form-action", cspOptions.FormAction},
+		{"frame-ancestors", cspOptions.FrameAncestors},
 	}
-	return csp
-}
 
-func (csp *CSPHandler) CSP(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		isStringAvailable := false
-		if csp.cspOptions.IsHeaderCreated {
-			if csp.cspOptions.IsReportOnly && csp.cspOptions.ReportURI != "" {
-				w.Header().Set("Content-Security-Policy-Report-Only", csp.cspOptions.HeaderString)
-			} else {
-				w.Header().Set("Content-Security-Policy", csp.cspOptions.HeaderString)
+	//nonceDirectives are the directives which receive the nonce, script-src-elem and style-src-elem take it too when they are set
+	var nonceDirectives []string
+	if cspOptions.Nonce {
+		nonceDirectives = cspOptions.NonceDirectives
+		if len(nonceDirectives) == 0 {
+			nonceDirectives = []string{"script-src", "style-src"}
+			if len(cspOptions.ScriptSrcElem) != 0 {
+				nonceDirectives = append(nonceDirectives, "script-src-elem")
+			}
+			if len(cspOptions.StyleSrcElem) != 0 {
+				nonceDirectives = append(nonceDirectives, "style-src-elem")
 			}
-			next.ServeHTTP(w, r)
-			return
 		}
-
-		if len(csp.cspOptions.DefaultSrc) != 0 {
-			isStringAvailable = true
-			csp.cspOptions.HeaderStrings = append(csp.cspOptions.HeaderStrings, createStringFromValues("default-src", csp.cspOptions.DefaultSrc))
+		for _, name := range nonceDirectives {
+			if !nonceCapable[name] {
+				return nil, &CSPError{Directive: "nonce-directives", Message: fmt.Sprintf("nonces are not supported in %q", name)}
+			}
 		}
+	}
 
-		if len(csp.cspOptions.ScriptSrc) != 0 {
-			isStringAvailable = true
-			csp.cspOptions.HeaderStrings = append(csp.cspOptions.HeaderStrings, createStringFromValues("script-src", csp.cspOptions.ScriptSrc))
-		}
+	//extraSources are the hashes and the nonce added to the script and style directives.
+	//The hashes allow elements, so they go to the -elem directive too whenever it is sent
+	scriptHashes, styleHashes, err := inlineHashes(cspOptions)
+	if err != nil {
+		return nil, err
+	}
+	extraSources := map[string][]string{
+		"script-src": scriptHashes,
+		"style-src":  styleHashes,
+	}
+	if len(cspOptions.ScriptSrcElem) != 0 || contains(nonceDirectives, "script-src-elem") {
+		extraSources["script-src-elem"] = scriptHashes
+	}
+	if len(cspOptions.StyleSrcElem) != 0 || contains(nonceDirectives, "style-src-elem") {
+		extraSources["style-src-elem"] = styleHashes
+	}
+	for _, name := range nonceDirectives {
+		extraSources[name] = append(extraSources[name][:len(extraSources[name]):len(extraSources[name])], "'nonce-"+noncePlaceholder+"'")
+	}
+	//an -elem directive which is not set falls back to its parent directive, any other one to default-src
+	fallbacks := map[string][]string{
+		"script-src-elem": cspOptions.ScriptSrc,
+		"style-src-elem":  cspOptions.StyleSrc,
+	}
 
-		if len(csp.cspOptions.StyleSrc) != 0 {
-			isStringAvailable = true
-			csp.cspOptions.HeaderStrings = append(csp.cspOptions.HeaderStrings, createStringFromValues("style-src", csp.cspOptions.StyleSrc))
+	var policy []string
+	for _, d := range directives {
+		values := d.values
+		extra := extraSources[d.name]
+		if len(extra) != 0 && len(values) == 0 {
+			//the directive replaces its fallback for its resources, so it keeps the sources the fallback allows
+			fallback := fallbacks[d.name]
+			if len(fallback) == 0 {
+				fallback = cspOptions.DefaultSrc
+			}
+			for _, value := range fallback {
+				if !strings.EqualFold(value, "'none'") {
+					values = append(values, value)
+				}
+			}
 		}
-
-		if len(csp.cspOptions.ImgSrc) != 0 {
-			isStringAvailable = true
-			csp.cspOptions.HeaderStrings = append(csp.cspOptions.HeaderStrings, createStringFromValues("img-src", csp.cspOptions.ImgSrc))
+		if len(values) == 0 && len(extra) == 0 {
+			continue
 		}
-
-		if len(csp.cspOptions.ConnectSrc) != 0 {
-			isStringAvailable = true
-			csp.cspOptions.HeaderStrings = append(csp.cspOptions.HeaderStrings, createStringFromValues("connect-src", csp.cspOptions.ConnectSrc))
+		if err := validateSources(d.name, values); err != nil {
+			return nil, err
 		}
-		if len(csp.cspOptions.FontSrc) != 0 {
-			isStringAvailable = true
-			csp.cspOptions.HeaderStrings = append(csp.cspOptions.HeaderStrings, createStringFromValues("font-src", csp.cspOptions.FontSrc))
+		if len(extra) != 0 {
+			for _, value := range values {
+				if strings.EqualFold(value, "'none'") {
+					return nil, &CSPError{Directive: d.name, Message: "'none' can not be combined with a nonce or hash"}
+				}
+			}
+			values = append(values[:len(values):len(values)], extra...)
 		}
-
-		if len(csp.cspOptions.ObjectSrc) != 0 {
-			isStringAvailable = true
-			csp.cspOptions.HeaderStrings = append(csp.cspOptions.HeaderStrings, createStringFromValues("object-src", csp.cspOptions.ObjectSrc))
+		policy = append(policy, serializeDirective(d.name, values))
+	}
+	if len(cspOptions.Sandbox) != 0 {
+		for _, value := range cspOptions.Sandbox {
+			if !sandboxTokens[strings.ToLower(value)] {
+				return nil, &CSPError{Directive: "sandbox", Message: fmt.Sprintf("unknown flag %q", value)}
+			}
 		}
-
-		if len(csp.cspOptions.MediaSrc) != 0 {
-			isStringAvailable = true
-			csp.cspOptions.HeaderStrings = append(csp.cspOptions.HeaderStrings, createStringFromValues("media-src", csp.cspOptions.MediaSrc))
+		policy = append(policy, serializeDirective("sandbox", cspOptions.Sandbox))
+	}
+	if cspOptions.UpgradeInsecureRequests {
+		policy = append(policy, "upgrade-insecure-requests")
+	}
+	if cspOptions.BlockAllMixedContent {
+		policy = append(policy, "block-all-mixed-content")
+	}
+	if len(cspOptions.RequireTrustedTypesFor) != 0 {
+		for _, value := range cspOptions.RequireTrustedTypesFor {
+			if value != CSPScript {
+				return nil, &CSPError{Directive: "require-trusted-types-for", Message: fmt.Sprintf("unknown sink group %q, only %s is defined", value, CSPScript)}
+			}
 		}
-
-		if len(csp.cspOptions.ChildSrc) != 0 {
-			isStringAvailable = true
-			csp.cspOptions.HeaderStrings = append(csp.cspOptions.HeaderStrings, createStringFromValues("child-src", csp.cspOptions.ChildSrc))
+		policy = append(policy, serializeDirective("require-trusted-types-for", cspOptions.RequireTrustedTypesFor))
+	}
+	if len(cspOptions.TrustedTypes) != 0 {
+		for _, value := range cspOptions.TrustedTypes {
+			if err := validateToken("trusted-types", value); err != nil {
+				return nil, err
+			}
+			if strings.EqualFold(value, CSPNone) && len(cspOptions.TrustedTypes) > 1 {
+				return nil, &CSPError{Directive: "trusted-types", Message: "'none' can not be combined with policy names"}
+			}
 		}
-
-		if len(csp.cspOptions.FormAction) != 0 {
-			isStringAvailable = true
-			csp.cspOptions.HeaderStrings = append(csp.cspOptions.HeaderStrings, createStringFromValues("form-action", csp.cspOptions.FormAction))
+		policy = append(policy, serializeDirective("trusted-types", cspOptions.TrustedTypes))
+	}
+	custom, err := customDirectives(cspOptions.Directives, directives)
+	if err != nil {
+		return nil, err
+	}
+	policy = append(policy, custom...)
+	if cspOptions.ReportURI != "" {
+		if err := validateToken("report-uri", cspOptions.ReportURI); err != nil {
+			return nil, err
 		}
-
-		if len(csp.cspOptions.FrameAncestors) != 0 {
-			isStringAvailable = true
-			csp.cspOptions.HeaderStrings = append(csp.cspOptions.HeaderStrings, createStringFromValues("frame-ancestors", csp.cspOptions.FrameAncestors))
+		policy = append(policy, serializeDirective("report-uri", []string{cspOptions.ReportURI}))
+	}
+	if cspOptions.ReportTo != "" {
+		if err := validateToken("report-to", cspOptions.ReportTo); err != nil {
+			return nil, err
 		}
+		policy = append(policy, serializeDirective("report-to", []string{cspOptions.ReportTo}))
+	}
+	if len(policy) == 0 {
+		return nil, &CSPError{Directive: "policy", Message: "no directive is set"}
+	}
 
-		if len(csp.cspOptions.PluginTypes) != 0 {
-			isStringAvailable = true
-			csp.cspOptions.HeaderStrings = append(csp.cspOptions.HeaderStrings, createStringFromValues("plugin-types", csp.cspOptions.PluginTypes))
+	headerName := "Content-Security-Policy"
+	if cspOptions.IsReportOnly {
+		//a report only policy without an endpoint has no effect at all
+		if cspOptions.ReportURI == "" && cspOptions.ReportTo == "" {
+			return nil, &CSPError{Directive: "report-uri", Message: "a report only policy needs report-uri or report-to"}
 		}
+		headerName = "Content-Security-Policy-Report-Only"
+	}
+	csp := &CSPHandler{
+		headerName:    headerName,
+		headerValue:   strings.Join(policy, "; "),
+		hashAlgorithm: hashAlgorithm(cspOptions),
+		allowedHashes: map[string]bool{},
+		audit:         cspOptions.AuditInline,
+	}
+	for _, hash := range append(scriptHashes, styleHashes...) {
+		csp.allowedHashes[hash] = true
+	}
+	if cspOptions.Nonce {
+		csp.nonceParts = strings.Split(csp.headerValue, noncePlaceholder)
+		csp.headerValue = strings.Join(csp.nonceParts, "{nonce}")
+	}
+	return csp, nil
+}
+
+func serializeDirective(name string, values []string) string {
+	if len(values) == 0 {
+		return name
+	}
+	return name + " " + strings.Join(values, " ")
+}
 
-		if len(csp.cspOptions.Sandbox) != 0 {
-			isStringAvailable = true
-			csp.cspOptions.HeaderStrings = append(csp.cspOptions.HeaderStrings, createStringFromValues("sandbox", csp.cspOptions.Sandbox))
+//typedDirectives are the directives with their own field in CSPOptions besides the fetch directives
+var typedDirectives = map[string]bool{
+	"sandbox":                   true,
+	"upgrade-insecure-requests": true,
+	"block-all-mixed-content":   true,
+	"require-trusted-types-for": true,
+	"trusted-types":             true,
+	"report-uri":                true,
+	"report-to":                 true,
+	"plugin-types":              true,
+}
+
+//customDirectives serializes CSPOptions.Directives sorted by name, a directive with a typed field has to use the field
+func customDirectives(custom map[string][]string, fetchDirectives []struct {
+	name   string
+	values []string
+}) ([]string, error) {
+	names := make([]string, 0, len(custom))
+	for name := range custom {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var policy []string
+	for _, name := range names {
+		if name == "" || strings.Trim(name, "abcdefghijklmnopqrstuvwxyz0123456789-") != "" {
+			return nil, &CSPError{Directive: name, Message: "directive names are lowercase letters, digits and dashes"}
 		}
-		if csp.cspOptions.ReportURI != "" {
-			isStringAvailable = true
-			csp.cspOptions.HeaderStrings = append(csp.cspOptions.HeaderStrings, createStringFromValue("report-uri", csp.cspOptions.ReportURI))
+		typed := typedDirectives[name]
+		for _, d := range fetchDirectives {
+			typed = typed || d.name == name
+		}
+		if typed {
+			return nil, &CSPError{Directive: name, Message: "set by its field of CSPOptions, not by Directives"}
+		}
+		for _, value := range custom[name] {
+			if err := validateToken(name, value); err != nil {
+				return nil, err
+			}
 		}
+		policy = append(policy, serializeDirective(name, custom[name]))
+	}
+	return policy, nil
+}
 
-		if csp.cspOptions.IsReportOnly && csp.cspOptions.ReportURI != "" {
-			csp.cspOptions.HeaderString = strings.Join(csp.cspOptions.HeaderStrings, "; ")
-			csp.cspOptions.IsHeaderCreated = true
+//validateSources checks the values of a fetch directive, 'none' must be the only source
+func validateSources(directive string, values []string) error {
+	for _, value := range values {
+		if err := validateToken(directive, value); err != nil {
+			return err
+		}
+		if cspKeywords[strings.ToLower(value)] {
+			return &CSPError{Directive: directive, Message: fmt.Sprintf("keyword %s must be quoted like '%s'", value, value)}
+		}
+		if strings.EqualFold(value, "'none'") && len(values) > 1 {
+			return &CSPError{Directive: directive, Message: "'none' can not be combined with other sources"}
+		}
+	}
+	return nil
+}
 
-			w.Header().Set("Content-Security-Policy-Report-Only", csp.cspOptions.HeaderString)
-			next.ServeHTTP(w, r)
-			return
+//validateToken rejects values which would change the structure of the header, like ; which starts another directive
+func validateToken(directive string, value string) error {
+	if value == "" {
+		return &CSPError{Directive: directive, Message: "empty value"}
+	}
+	for _, c := range value {
+		if c <= ' ' || c > '~' || c == ';' || c == ',' {
+			return &CSPError{Directive: directive, Message: fmt.Sprintf("invalid character %q in %q", c, value)}
 		}
+	}
+	return nil
+}
 
-		if isStringAvailable {
-			csp.cspOptions.HeaderString = strings.Join(csp.cspOptions.HeaderStrings, "; ")
-			csp.cspOptions.IsHeaderCreated = true
+//header returns the policy with the nonce of the request
+func (csp *CSPHandler) header(nonce string) string {
+	if csp.nonceParts == nil {
+		return csp.headerValue
+	}
+	return strings.Join(csp.nonceParts, nonce)
+}
 
-			w.Header().Set("Content-Security-Policy", csp.cspOptions.HeaderString)
+//servePolicies sends the policies with one nonce for the request and audits the response for those which ask for it.
+//The first policy of a header replaces what the header held, the others are added as more headers
+func servePolicies(w http.ResponseWriter, r *http.Request, next http.Handler, policies []*CSPHandler) {
+	nonce := ""
+	var audited []*CSPHandler
+	for _, csp := range policies {
+		if csp.nonceParts != nil && nonce == "" {
+			var err error
+			if nonce, err = newCSPNonce(); err != nil {
+				WriteError(w, r, err)
+				return
+			}
+			r = SetCSPNonce(r, nonce)
 		}
-		next.ServeHTTP(w, r)
-	})
+		if csp.audit != nil {
+			audited = append(audited, csp)
+		}
+	}
+	set := map[string]bool{}
+	for _, csp := range policies {
+		if set[csp.headerName] {
+			w.Header().Add(csp.headerName, csp.header(nonce))
+		} else {
+			w.Header().Set(csp.headerName, csp.header(nonce))
+			set[csp.headerName] = true
+		}
+	}
+	if len(audited) != 0 {
+		serveAudited(w, r, next, audited)
+		return
+	}
+	next.ServeHTTP(w, r)
+}
+
+//HeaderName func returns Content-Security-Policy or Content-Security-Policy-Report-Only
+func (csp *CSPHandler) HeaderName() string {
+	return csp.headerName
+}
 
+//HeaderValue func returns the serialized policy sent by the middleware, with {nonce} in place of the nonce of a request
+func (csp *CSPHandler) HeaderValue() string {
+	return csp.headerValue
+}
+
+//CSP middleware sets the policy header on every response, with a new nonce for every request if the policy uses one
+func (csp *CSPHandler) CSP(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		servePolicies(w, r, next, []*CSPHandler{csp})
+	})
 }
@@ -0,0 +1,169 @@
+package goat
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"fmt"
+	"hash"
+	"io/fs"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+//cspHashes maps the hash algorithms CSP supports to their constructors
+var cspHashes = map[string]func() hash.Hash{
+	"sha256": sha256.New,
+	"sha384": sha512.New384,
+	"sha512": sha512.New,
+}
+
+//CSPHash func returns the source allowing an inline script or style by hash, e.g. 'sha256-…'.
+//The algorithm is sha256, sha384 or sha512
+func CSPHash(algorithm string, content string) (string, error) {
+	newHash, ok := cspHashes[algorithm]
+	if !ok {
+		return "", &CSPError{Directive: "hash-algorithm", Message: fmt.Sprintf("unsupported algorithm %q", algorithm)}
+	}
+	h := newHash()
+	h.Write([]byte(content))
+	return "'" + algorithm + "-" + base64.StdEncoding.EncodeToString(h.Sum(nil)) + "'", nil
+}
+
+func hashAlgorithm(cspOptions CSPOptions) string {
+	if cspOptions.HashAlgorithm == "" {
+		return "sha256"
+	}
+	return strings.ToLower(cspOptions.HashAlgorithm)
+}
+
+//inlineHashes returns the hash sources of the inline contents and files of the options for script-src and style-src
+func inlineHashes(cspOptions CSPOptions) ([]string, []string, error) {
+	algorithm := hashAlgorithm(cspOptions)
+	if _, ok := cspHashes[algorithm]; !ok {
+		return nil, nil, &CSPError{Directive: "hash-algorithm", Message: fmt.Sprintf("unsupported algorithm %q", cspOptions.HashAlgorithm)}
+	}
+	if cspOptions.HashFS == nil && len(cspOptions.ScriptFiles)+len(cspOptions.StyleFiles) != 0 {
+		return nil, nil, &CSPError{Directive: "hash-fs", Message: "ScriptFiles and StyleFiles need a HashFS"}
+	}
+
+	collect := func(directive string, contents []string, patterns []string) ([]string, error) {
+		for _, pattern := range patterns {
+			names, err := fs.Glob(cspOptions.HashFS, pattern)
+			if err != nil {
+				return nil, &CSPError{Directive: directive, Message: fmt.Sprintf("invalid pattern %q: %v", pattern, err)}
+			}
+			if len(names) == 0 {
+				return nil, &CSPError{Directive: directive, Message: fmt.Sprintf("no file matches %q", pattern)}
+			}
+			for _, name := range names {
+				b, err := fs.ReadFile(cspOptions.HashFS, name)
+				if err != nil {
+					return nil, fmt.Errorf("goat: csp %s: %w", directive, err)
+				}
+				contents = append(contents, string(b))
+			}
+		}
+		var sources []string
+		seen := map[string]bool{}
+		for _, content := range contents {
+			source, _ := CSPHash(algorithm, content)
+			if !seen[source] {
+				seen[source] = true
+				sources = append(sources, source)
+			}
+		}
+		return sources, nil
+	}
+
+	scripts, err := collect("script-src", cspOptions.InlineScripts, cspOptions.ScriptFiles)
+	if err != nil {
+		return nil, nil, err
+	}
+	styles, err := collect("style-src", cspOptions.InlineStyles, cspOptions.StyleFiles)
+	if err != nil {
+		return nil, nil, err
+	}
+	return scripts, styles, nil
+}
+
+//cspAuditMaxSize is the largest HTML body held back for the audit, larger responses are streamed unchecked
+const cspAuditMaxSize = 1 << 20
+
+//InlineViolation struct describes an inline script or style which the policy does not allow
+type InlineViolation struct {
+	//Element is script or style
+	Element string
+	//Hash is the source which would allow the element, in the algorithm of the policy
+	Hash string
+	//Snippet is the start of the content
+	Snippet string
+}
+
+var (
+	inlineScriptPattern = regexp.MustCompile(`(?is)<script\b([^>]*)>(.*?)</script\s*>`)
+	inlineStylePattern  = regexp.MustCompile(`(?is)<style\b([^>]*)>(.*?)</style\s*>`)
+	srcAttrPattern      = regexp.MustCompile(`(?i)\ssrc\s*=`)
+	nonceAttrPattern    = regexp.MustCompile(`(?i)\snonce\s*=\s*["']?([^"'\s>]+)`)
+	typeAttrPattern     = regexp.MustCompile(`(?i)\stype\s*=\s*["']?([^"'\s>]+)`)
+)
+
+//serveAudited holds the response back to scan its inline scripts and styles against each of the policies
+func serveAudited(w http.ResponseWriter, r *http.Request, next http.Handler, policies []*CSPHandler) {
+	bw := NewBufferedResponseWriter(w, cspAuditMaxSize)
+	next.ServeHTTP(bw, r)
+	if bw.Buffered() {
+		contentType := bw.Header().Get("Content-Type")
+		if contentType == "" {
+			contentType = http.DetectContentType(bw.Body())
+		}
+		if strings.HasPrefix(strings.ToLower(contentType), "text/html") {
+			for _, csp := range policies {
+				if missing := csp.auditInline(CSPNonce(r), bw.Body()); len(missing) != 0 {
+					csp.audit(r, missing)
+				}
+			}
+		}
+	}
+	bw.Finish()
+}
+
+//auditInline returns the inline scripts and styles of the page which neither a hash nor the nonce allows
+func (csp *CSPHandler) auditInline(nonce string, page []byte) []InlineViolation {
+	var missing []InlineViolation
+	check := func(element string, pattern *regexp.Regexp) {
+		for _, match := range pattern.FindAllSubmatch(page, -1) {
+			attrs, content := match[1], string(match[2])
+			if element == "script" {
+				if srcAttrPattern.Match(attrs) {
+					continue
+				}
+				//data blocks like application/ld+json are not executed
+				if typ := typeAttrPattern.FindSubmatch(attrs); typ != nil && !executableScriptType(string(typ[1])) {
+					continue
+				}
+			}
+			if attr := nonceAttrPattern.FindSubmatch(attrs); attr != nil && nonce != "" && string(attr[1]) == nonce {
+				continue
+			}
+			source, _ := CSPHash(csp.hashAlgorithm, content)
+			if csp.allowedHashes[source] {
+				continue
+			}
+			snippet := strings.TrimSpace(content)
+			if len(snippet) > 60 {
+				snippet = snippet[:60]
+			}
+			missing = append(missing, InlineViolation{Element: element, Hash: source, Snippet: snippet})
+		}
+	}
+	check("script", inlineScriptPattern)
+	check("style", inlineStylePattern)
+	return missing
+}
+
+func executableScriptType(typ string) bool {
+	typ = strings.ToLower(typ)
+	return typ == "module" || strings.Contains(typ, "javascript") || strings.Contains(typ, "ecmascript")
+}
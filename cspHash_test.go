@@ -0,0 +1,100 @@
+package goat
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_CSPHash(t *testing.T) {
+	//the example of the CSP spec
+	source, err := CSPHash("sha256", "alert('Hello, world.');")
+	assert.NoError(t, err)
+	assert.Equal(t, "'sha256-qznLcsROx4GACP2dm0UCKCzCG+HiZ1guq6ZZDob/Tng='", source, "Hash does not match")
+
+	source, err = CSPHash("sha384", "a")
+	assert.NoError(t, err)
+	assert.Contains(t, source, "'sha384-", "Algorithm not used")
+	_, err = CSPHash("md5", "a")
+	assert.Error(t, err, "Unsupported algorithm accepted")
+}
+
+func Test_CSP_Hashes(t *testing.T) {
+	files := fstest.MapFS{
+		"inline/theme.js":  {Data: []byte("setTheme()")},
+		"inline/init.js":   {Data: []byte("init()")},
+		"inline/reset.css": {Data: []byte("body{margin:0}")},
+	}
+	csp, err := NewCSP(CSPOptions{
+		DefaultSrc:    []string{"'self'"},
+		ScriptSrc:     []string{"'self'"},
+		InlineScripts: []string{"alert('Hello, world.');"},
+		HashFS:        files,
+		ScriptFiles:   []string{"inline/*.js"},
+		StyleFiles:    []string{"inline/reset.css"},
+	})
+	assert.NoError(t, err)
+	hello, _ := CSPHash("sha256", "alert('Hello, world.');")
+	initHash, _ := CSPHash("sha256", "init()")
+	theme, _ := CSPHash("sha256", "setTheme()")
+	reset, _ := CSPHash("sha256", "body{margin:0}")
+	assert.Equal(t, "default-src 'self'; script-src 'self' "+hello+" "+initHash+" "+theme+"; style-src 'self' "+reset,
+		csp.HeaderValue(), "Hash sources not added")
+
+	csp, err = NewCSP(CSPOptions{
+		ScriptSrc:     []string{"'self'"},
+		ScriptSrcElem: []string{"https://cdn.example.com"},
+		InlineScripts: []string{"alert('Hello, world.');"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "script-src 'self' "+hello+"; script-src-elem https://cdn.example.com "+hello,
+		csp.HeaderValue(), "Hash sources not added to script-src-elem")
+
+	_, err = NewCSP(CSPOptions{DefaultSrc: []string{"'self'"}, HashFS: files, ScriptFiles: []string{"missing/*.js"}})
+	assert.Error(t, err, "Pattern without files accepted")
+	_, err = NewCSP(CSPOptions{DefaultSrc: []string{"'self'"}, ScriptFiles: []string{"*.js"}})
+	assert.Error(t, err, "Files without HashFS accepted")
+	_, err = NewCSP(CSPOptions{DefaultSrc: []string{"'self'"}, HashAlgorithm: "sha1"})
+	assert.Error(t, err, "Unsupported algorithm accepted")
+}
+
+func Test_CSP_AuditInline(t *testing.T) {
+	var missing []InlineViolation
+	csp, err := NewCSP(CSPOptions{
+		DefaultSrc:    []string{"'self'"},
+		InlineScripts: []string{"allowed()"},
+		Nonce:         true,
+		AuditInline: func(r *http.Request, violations []InlineViolation) {
+			missing = append(missing, violations...)
+		},
+	})
+	assert.NoError(t, err)
+	page := `<html><head><script>allowed()</script><script src="/app.js"></script>
+<script type="application/ld+json">{"@type": "Thing"}</script><script nonce="%s">nonced()</script>
+<script nonce="guess">forged()</script><style>p{color:red}</style></head></html>`
+	rr := httptest.NewRecorder()
+	csp.CSP(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprintf(w, page, CSPNonce(r))
+	})).ServeHTTP(rr, httptest.NewRequest("GET", "/", nil))
+
+	forged, _ := CSPHash("sha256", "forged()")
+	style, _ := CSPHash("sha256", "p{color:red}")
+	assert.Equal(t, []InlineViolation{
+		{Element: "script", Hash: forged, Snippet: "forged()"},
+		{Element: "style", Hash: style, Snippet: "p{color:red}"},
+	}, missing, "Violations do not match")
+	assert.Contains(t, rr.Body.String(), "forged()", "Audited response changed")
+
+	missing = nil
+	rr = httptest.NewRecorder()
+	csp.CSP(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"html": "<script>x()</script>"}`)
+	})).ServeHTTP(rr, httptest.NewRequest("GET", "/", nil))
+	assert.Empty(t, missing, "Response other than HTML audited")
+}
@@ -0,0 +1,54 @@
+package goat
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"html/template"
+	"net/http"
+)
+
+//noncePlaceholder marks where the nonce goes in a policy, validation keeps it out of the configured values
+const noncePlaceholder = "\x00"
+
+//newCSPNonce returns 128 random bits, the length the CSP spec recommends, in unpadded url safe base64
+//which html/template leaves unescaped in attributes
+func newCSPNonce() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+//CSPNonceAttr func returns the nonce attribute for a script or style element, e.g. <script {{.NonceAttr}}>.
+//It is empty if the CSP middleware did not create a nonce for the request
+func CSPNonceAttr(r *http.Request) template.HTMLAttr {
+	nonce := CSPNonce(r)
+	if nonce == "" {
+		return ""
+	}
+	return template.HTMLAttr(`nonce="` + nonce + `"`)
+}
+
+//CSPTemplateFuncs func returns the functions cspNonce and cspNonceAttr for html/template, bound to the request.
+//Parse the templates with CSPTemplateFuncs(nil), the functions then return empty strings, and bind them to each
+//request on a clone:
+//
+//	t, _ := base.Clone()
+//	t.Funcs(goat.CSPTemplateFuncs(r)).Execute(w, data)
+func CSPTemplateFuncs(r *http.Request) template.FuncMap {
+	return template.FuncMap{
+		"cspNonce": func() string {
+			if r == nil {
+				return ""
+			}
+			return CSPNonce(r)
+		},
+		"cspNonceAttr": func() template.HTMLAttr {
+			if r == nil {
+				return ""
+			}
+			return CSPNonceAttr(r)
+		},
+	}
+}
@@ -0,0 +1,89 @@
+package goat
+
+import (
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_CSP_Nonce(t *testing.T) {
+	csp, err := NewCSP(CSPOptions{
+		DefaultSrc: []string{"'self'"},
+		ScriptSrc:  []string{"'self'", "cdn.example.com"},
+		ImgSrc:     []string{"*"},
+		Nonce:      true,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "default-src 'self'; script-src 'self' cdn.example.com 'nonce-{nonce}'; style-src 'self' 'nonce-{nonce}'; img-src *",
+		csp.HeaderValue(), "Nonce not added to the directives")
+
+	var nonces []string
+	handler := csp.CSP(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		nonces = append(nonces, CSPNonce(r))
+	}))
+	first := httptest.NewRecorder()
+	handler.ServeHTTP(first, httptest.NewRequest("GET", "/", nil))
+	second := httptest.NewRecorder()
+	handler.ServeHTTP(second, httptest.NewRequest("GET", "/", nil))
+
+	if assert.Len(t, nonces, 2) {
+		assert.Len(t, nonces[0], 22, "Nonce is not 128 bits in base64")
+		assert.NotEqual(t, nonces[0], nonces[1], "Nonce reused")
+		assert.Equal(t, strings.ReplaceAll(csp.HeaderValue(), "{nonce}", nonces[0]), first.Header().Get("Content-Security-Policy"), "Nonce of the request not sent")
+		assert.Equal(t, strings.ReplaceAll(csp.HeaderValue(), "{nonce}", nonces[1]), second.Header().Get("Content-Security-Policy"), "Nonce of the request not sent")
+	}
+}
+
+func Test_CSP_NonceDirectives(t *testing.T) {
+	csp, err := NewCSP(CSPOptions{
+		DefaultSrc:      []string{"'none'"},
+		Nonce:           true,
+		NonceDirectives: []string{"script-src"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "default-src 'none'; script-src 'nonce-{nonce}'", csp.HeaderValue(), "Nonce directive does not match")
+
+	_, err = NewCSP(CSPOptions{ScriptSrc: []string{"'none'"}, Nonce: true})
+	assert.Error(t, err, "'none' combined with a nonce")
+	_, err = NewCSP(CSPOptions{DefaultSrc: []string{"'self'"}, Nonce: true, NonceDirectives: []string{"img-src"}})
+	assert.Error(t, err, "Nonce accepted for img-src")
+}
+
+func Test_CSP_NonceElemDirectives(t *testing.T) {
+	csp, err := NewCSP(CSPOptions{ScriptSrc: []string{"'self'"}, ScriptSrcElem: []string{"'self'"}, Nonce: true})
+	assert.NoError(t, err)
+	assert.Equal(t, "script-src 'self' 'nonce-{nonce}'; script-src-elem 'self' 'nonce-{nonce}'; style-src 'nonce-{nonce}'",
+		csp.HeaderValue(), "Nonce not added to script-src-elem")
+
+	csp, err = NewCSP(CSPOptions{
+		DefaultSrc:      []string{"'none'"},
+		StyleSrc:        []string{"https://cdn.example.com"},
+		Nonce:           true,
+		NonceDirectives: []string{"style-src-elem"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "default-src 'none'; style-src https://cdn.example.com; style-src-elem https://cdn.example.com 'nonce-{nonce}'",
+		csp.HeaderValue(), "Unset -elem directive does not start from its parent")
+}
+
+func Test_CSPTemplateFuncs(t *testing.T) {
+	base := template.Must(template.New("page").Funcs(CSPTemplateFuncs(nil)).Parse(
+		`<script {{cspNonceAttr}}>run()</script><style nonce="{{cspNonce}}"></style>`))
+
+	csp, err := NewCSP(CSPOptions{DefaultSrc: []string{"'self'"}, Nonce: true})
+	assert.NoError(t, err)
+	rr := httptest.NewRecorder()
+	var nonce string
+	csp.CSP(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		nonce = CSPNonce(r)
+		page, _ := base.Clone()
+		assert.NoError(t, page.Funcs(CSPTemplateFuncs(r)).Execute(w, nil))
+	})).ServeHTTP(rr, httptest.NewRequest("GET", "/", nil))
+	assert.Equal(t, `<script nonce="`+nonce+`">run()</script><style nonce="`+nonce+`"></style>`, rr.Body.String(), "Nonce not rendered")
+
+	assert.Equal(t, template.HTMLAttr(""), CSPNonceAttr(httptest.NewRequest("GET", "/", nil)), "Attribute without nonce")
+}
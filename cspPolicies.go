@@ -0,0 +1,121 @@
+package goat
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"net/http"
+)
+
+//CSPPolicies struct configures a CSPPolicySet, at least one policy has to be set
+type CSPPolicies struct {
+	//Enforce is sent as Content-Security-Policy, nil sends only the report only policies
+	Enforce *CSPOptions
+	//ReportOnly are sent as one Content-Security-Policy-Report-Only header each, every one needs a ReportURI or ReportTo
+	ReportOnly []CSPOptions
+	//Candidate is the policy being rolled out, it is enforced for RolloutPercent of the requests and sent report only
+	//for the rest, so it needs a ReportURI or ReportTo unless RolloutPercent is 100
+	Candidate *CSPOptions
+	//RolloutPercent is the share of requests, from 0 to 100, which enforce the Candidate
+	RolloutPercent float64
+	//RolloutKey, if set, samples by a key of the request like a session id so a client keeps its decision,
+	//requests without a key and every request without RolloutKey are sampled at random
+	RolloutKey func(r *http.Request) string
+}
+
+//CSPPolicySet struct sends an enforced policy together with report only policies, browsers enforce every
+//Content-Security-Policy header and report the violations of every Content-Security-Policy-Report-Only header.
+//All policies of a request share one nonce
+type CSPPolicySet struct {
+	enforce    *CSPHandler
+	reportOnly []*CSPHandler
+	//candidate is the enforced and candidateReport the report only form of the candidate policy
+	candidate       *CSPHandler
+	candidateReport *CSPHandler
+	rolloutPercent  float64
+	rolloutKey      func(r *http.Request) string
+}
+
+//NewCSPPolicySet func validates and serializes every policy once like NewCSP, a CSPError names the failing policy
+func NewCSPPolicySet(policies CSPPolicies) (*CSPPolicySet, error) {
+	if policies.Enforce == nil && len(policies.ReportOnly) == 0 && policies.Candidate == nil {
+		return nil, &CSPError{Directive: "policy", Message: "no policy is set"}
+	}
+	set := &CSPPolicySet{
+		rolloutPercent: policies.RolloutPercent,
+		rolloutKey:     policies.RolloutKey,
+	}
+	var err error
+	if policies.Enforce != nil {
+		if policies.Enforce.IsReportOnly {
+			return nil, &CSPError{Policy: "enforce", Directive: "policy", Message: "IsReportOnly is set, list the policy in ReportOnly"}
+		}
+		if set.enforce, err = newPolicy("enforce", *policies.Enforce, false); err != nil {
+			return nil, err
+		}
+	}
+	for i, options := range policies.ReportOnly {
+		csp, err := newPolicy(fmt.Sprintf("report-only[%d]", i), options, true)
+		if err != nil {
+			return nil, err
+		}
+		set.reportOnly = append(set.reportOnly, csp)
+	}
+	if policies.Candidate != nil {
+		if policies.RolloutPercent < 0 || policies.RolloutPercent > 100 {
+			return nil, &CSPError{Policy: "candidate", Directive: "policy", Message: fmt.Sprintf("rollout percent %v is not between 0 and 100", policies.RolloutPercent)}
+		}
+		if policies.RolloutPercent > 0 {
+			if set.candidate, err = newPolicy("candidate", *policies.Candidate, false); err != nil {
+				return nil, err
+			}
+		}
+		if policies.RolloutPercent < 100 {
+			if set.candidateReport, err = newPolicy("candidate", *policies.Candidate, true); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return set, nil
+}
+
+//newPolicy creates one policy of a set, naming it in the CSPError
+func newPolicy(name string, options CSPOptions, reportOnly bool) (*CSPHandler, error) {
+	options.IsReportOnly = reportOnly
+	csp, err := NewCSP(options)
+	var cspErr *CSPError
+	if errors.As(err, &cspErr) {
+		cspErr.Policy = name
+	}
+	return csp, err
+}
+
+//CSP middleware sets the headers of every policy, with the candidate enforced or reported as sampled for the request
+func (set *CSPPolicySet) CSP(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var policies []*CSPHandler
+		if set.enforce != nil {
+			policies = append(policies, set.enforce)
+		}
+		if set.candidate != nil && (set.candidateReport == nil || set.sampled(r)) {
+			policies = append(policies, set.candidate)
+		} else if set.candidateReport != nil {
+			policies = append(policies, set.candidateReport)
+		}
+		policies = append(policies, set.reportOnly...)
+		servePolicies(w, r, next, policies)
+	})
+}
+
+//sampled reports whether the request enforces the candidate
+func (set *CSPPolicySet) sampled(r *http.Request) bool {
+	if set.rolloutKey != nil {
+		if key := set.rolloutKey(r); key != "" {
+			h := fnv.New32a()
+			h.Write([]byte(key))
+			return float64(h.Sum32()%10000) < set.rolloutPercent*100
+		}
+	}
+	return rand.Float64()*100 < set.rolloutPercent
+}
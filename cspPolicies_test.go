@@ -0,0 +1,103 @@
+package goat
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_CSPPolicySet(t *testing.T) {
+	set, err := NewCSPPolicySet(CSPPolicies{
+		Enforce: &CSPOptions{DefaultSrc: []string{CSPSelf}, ScriptSrc: []string{CSPSelf, CSPUnsafeInline}},
+		ReportOnly: []CSPOptions{
+			{DefaultSrc: []string{CSPSelf}, Nonce: true, ReportURI: "/csp"},
+			{DefaultSrc: []string{CSPNone}, ReportTo: "csp"},
+		},
+	})
+	assert.NoError(t, err)
+	var nonce string
+	rr := httptest.NewRecorder()
+	set.CSP(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		nonce = CSPNonce(r)
+	})).ServeHTTP(rr, httptest.NewRequest("GET", "/", nil))
+
+	assert.Equal(t, []string{"default-src 'self'; script-src 'self' 'unsafe-inline'"}, rr.Header().Values("Content-Security-Policy"), "Enforced policy does not match")
+	assert.NotEmpty(t, nonce, "Nonce not shared with the handler")
+	assert.Equal(t, []string{
+		"default-src 'self'; script-src 'self' 'nonce-" + nonce + "'; style-src 'self' 'nonce-" + nonce + "'; report-uri /csp",
+		"default-src 'none'; report-to csp",
+	}, rr.Header().Values("Content-Security-Policy-Report-Only"), "Report only policies do not match")
+}
+
+func Test_CSPPolicySet_Rollout(t *testing.T) {
+	policies := CSPPolicies{
+		Enforce:        &CSPOptions{DefaultSrc: []string{CSPSelf, "cdn.example.com"}},
+		Candidate:      &CSPOptions{DefaultSrc: []string{CSPSelf}, ReportURI: "/csp"},
+		RolloutPercent: 25,
+		RolloutKey: func(r *http.Request) string {
+			return r.URL.Query().Get("session")
+		},
+	}
+	set, err := NewCSPPolicySet(policies)
+	assert.NoError(t, err)
+	handler := set.CSP(&TestNoCacheHandler{})
+	serve := func(session string) http.Header {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest("GET", "/?session="+session, nil))
+		return rr.Header()
+	}
+
+	enforced := 0
+	for i := 0; i < 2000; i++ {
+		header := serve(strconv.Itoa(i))
+		switch len(header.Values("Content-Security-Policy")) {
+		case 2:
+			enforced++
+			assert.Empty(t, header.Values("Content-Security-Policy-Report-Only"), "Enforced candidate also reported")
+		case 1:
+			assert.Equal(t, "default-src 'self'; report-uri /csp", header.Get("Content-Security-Policy-Report-Only"), "Candidate not reported")
+		default:
+			t.Fatalf("unexpected policies %v", header)
+		}
+	}
+	assert.InDelta(t, 500, enforced, 100, "Rollout share does not match")
+	first := len(serve("session-42").Values("Content-Security-Policy"))
+	for i := 0; i < 10; i++ {
+		assert.Equal(t, first, len(serve("session-42").Values("Content-Security-Policy")), "Decision not kept for the key")
+	}
+
+	policies.RolloutPercent = 100
+	policies.Candidate = &CSPOptions{DefaultSrc: []string{CSPSelf}}
+	set, err = NewCSPPolicySet(policies)
+	assert.NoError(t, err, "Fully rolled out candidate needs no report endpoint")
+	rr := httptest.NewRecorder()
+	set.CSP(&TestNoCacheHandler{}).ServeHTTP(rr, httptest.NewRequest("GET", "/", nil))
+	assert.Len(t, rr.Header().Values("Content-Security-Policy"), 2, "Candidate not enforced")
+}
+
+func Test_NewCSPPolicySet_Errors(t *testing.T) {
+	tests := []struct {
+		policies CSPPolicies
+		policy   string
+	}{
+		{CSPPolicies{}, ""},
+		{CSPPolicies{ReportOnly: []CSPOptions{{DefaultSrc: []string{CSPSelf}, ReportURI: "/csp"}, {DefaultSrc: []string{CSPSelf}}}}, "report-only[1]"},
+		{CSPPolicies{Enforce: &CSPOptions{DefaultSrc: []string{"self"}}}, "enforce"},
+		{CSPPolicies{Enforce: &CSPOptions{DefaultSrc: []string{CSPSelf}, ReportURI: "/csp", IsReportOnly: true}}, "enforce"},
+		{CSPPolicies{Candidate: &CSPOptions{DefaultSrc: []string{CSPSelf}}, RolloutPercent: 10}, "candidate"},
+		{CSPPolicies{Candidate: &CSPOptions{DefaultSrc: []string{CSPSelf}, ReportURI: "/csp"}, RolloutPercent: 150}, "candidate"},
+	}
+	for _, tt := range tests {
+		_, err := NewCSPPolicySet(tt.policies)
+		var cspErr *CSPError
+		if assert.True(t, errors.As(err, &cspErr), "No CSPError for %+v: %v", tt.policies, err) {
+			assert.Equal(t, tt.policy, cspErr.Policy, "Policy does not match for %+v", tt.policies)
+			assert.True(t, strings.HasPrefix(err.Error(), "goat: csp "+tt.policy), "Error does not name the policy: %v", err)
+		}
+	}
+}
@@ -0,0 +1,431 @@
+package goat
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+//CSPReport struct is a violation report of a browser, normalized from the legacy application/csp-report
+//format and the Reporting API application/reports+json format
+type CSPReport struct {
+	DocumentURI        string    `json:"documentURI"`
+	Referrer           string    `json:"referrer,omitempty"`
+	BlockedURI         string    `json:"blockedURI,omitempty"`
+	EffectiveDirective string    `json:"effectiveDirective"`
+	OriginalPolicy     string    `json:"originalPolicy,omitempty"`
+	Disposition        string    `json:"disposition,omitempty"` //enforce or report
+	SourceFile         string    `json:"sourceFile,omitempty"`
+	LineNumber         int       `json:"lineNumber,omitempty"`
+	ColumnNumber       int       `json:"columnNumber,omitempty"`
+	StatusCode         int       `json:"statusCode,omitempty"`
+	Sample             string    `json:"sample,omitempty"`
+	UserAgent          string    `json:"userAgent,omitempty"`
+	Received           time.Time `json:"received"`
+}
+
+//CSPReportSink receives the reports accepted by a CSPReportHandler, it is called from concurrent requests
+type CSPReportSink interface {
+	Report(report CSPReport)
+}
+
+//CSPReportSinkFunc type is an adapter to use a func as a CSPReportSink
+type CSPReportSinkFunc func(report CSPReport)
+
+//Report func calls f(report)
+func (f CSPReportSinkFunc) Report(report CSPReport) {
+	f(report)
+}
+
+//CSPReportOptions struct configures a CSPReportHandler
+type CSPReportOptions struct {
+	//MaxBodySize is the largest request body in bytes, 64KB if 0
+	MaxBodySize int64
+	//MaxReports is the largest number of reports taken from a Reporting API batch, 100 if 0
+	MaxReports int
+	//DedupWindow drops repeats of a report, same document, directive, blocked URI and source location, within the window.
+	//One minute if 0, a negative window keeps every report
+	DedupWindow time.Duration
+}
+
+//CSPReportHandler struct receives violation reports sent to the ReportURI of a policy and passes them to a sink
+type CSPReportHandler struct {
+	sink    CSPReportSink
+	options CSPReportOptions
+
+	mu sync.Mutex
+	//seen holds when each report was passed to the sink last
+	seen map[cspReportKey]time.Time
+}
+
+type cspReportKey struct {
+	documentURI string
+	directive   string
+	blockedURI  string
+	sourceFile  string
+	line        int
+	column      int
+}
+
+//maxSeenReports bounds the dedup memory, expired keys are dropped once it is reached
+const maxSeenReports = 10000
+
+//NewCSPReportHandler func creates a handler passing the reports it receives to sink
+func NewCSPReportHandler(sink CSPReportSink, options CSPReportOptions) *CSPReportHandler {
+	if options.MaxBodySize <= 0 {
+		options.MaxBodySize = 64 << 10
+	}
+	if options.MaxReports <= 0 {
+		options.MaxReports = 100
+	}
+	if options.DedupWindow == 0 {
+		options.DedupWindow = time.Minute
+	}
+	return &CSPReportHandler{
+		sink:    sink,
+		options: options,
+		seen:    map[cspReportKey]time.Time{},
+	}
+}
+
+//legacyCSPReport is the body of an application/csp-report request
+type legacyCSPReport struct {
+	Report *struct {
+		DocumentURI        string      `json:"document-uri"`
+		Referrer           string      `json:"referrer"`
+		BlockedURI         string      `json:"blocked-uri"`
+		ViolatedDirective  string      `json:"violated-directive"`
+		EffectiveDirective string      `json:"effective-directive"`
+		OriginalPolicy     string      `json:"original-policy"`
+		Disposition        string      `json:"disposition"`
+		SourceFile         string      `json:"source-file"`
+		LineNumber         json.Number `json:"line-number"`
+		ColumnNumber       json.Number `json:"column-number"`
+		StatusCode         json.Number `json:"status-code"`
+		ScriptSample       string      `json:"script-sample"`
+	} `json:"csp-report"`
+}
+
+//reportingAPIReport is an entry of an application/reports+json batch
+type reportingAPIReport struct {
+	Type      string `json:"type"`
+	URL       string `json:"url"`
+	UserAgent string `json:"user_agent"`
+	Body      struct {
+		DocumentURL        string      `json:"documentURL"`
+		Referrer           string      `json:"referrer"`
+		BlockedURL         string      `json:"blockedURL"`
+		EffectiveDirective string      `json:"effectiveDirective"`
+		OriginalPolicy     string      `json:"originalPolicy"`
+		Disposition        string      `json:"disposition"`
+		SourceFile         string      `json:"sourceFile"`
+		LineNumber         json.Number `json:"lineNumber"`
+		ColumnNumber       json.Number `json:"columnNumber"`
+		StatusCode         json.Number `json:"statusCode"`
+		Sample             string      `json:"sample"`
+	} `json:"body"`
+}
+
+//ServeHTTP func accepts a POST with one legacy report or a Reporting API batch and answers 204.
+//Other methods get 405, other media types 415, bodies over the max size 413 and malformed reports 400
+func (h *CSPReportHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		WriteError(w, r, NewHTTPError(http.StatusMethodNotAllowed, ""))
+		return
+	}
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "application/csp-report" && mediaType != "application/reports+json" && mediaType != "application/json" {
+		WriteError(w, r, NewHTTPError(http.StatusUnsupportedMediaType, "expected application/csp-report or application/reports+json"))
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, h.options.MaxBodySize))
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			WriteError(w, r, NewHTTPError(http.StatusRequestEntityTooLarge, fmt.Sprintf("report exceeds %d bytes", h.options.MaxBodySize)))
+			return
+		}
+		WriteError(w, r, &HTTPError{Status: http.StatusBadRequest, Message: "unreadable report", Err: err})
+		return
+	}
+
+	var reports []CSPReport
+	if mediaType == "application/reports+json" {
+		reports, err = parseReportingAPIReports(body, h.options.MaxReports)
+	} else {
+		reports, err = parseLegacyCSPReport(body, r.UserAgent())
+	}
+	if err != nil {
+		WriteError(w, r, &HTTPError{Status: http.StatusBadRequest, Message: "malformed report", Err: err})
+		return
+	}
+
+	now := time.Now()
+	for _, report := range reports {
+		report.Received = now
+		if h.firstSeen(report, now) {
+			h.sink.Report(report)
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+//firstSeen reports whether the report was not passed to the sink within the dedup window
+func (h *CSPReportHandler) firstSeen(report CSPReport, now time.Time) bool {
+	if h.options.DedupWindow < 0 {
+		return true
+	}
+	key := cspReportKey{
+		documentURI: report.DocumentURI,
+		directive:   report.EffectiveDirective,
+		blockedURI:  report.BlockedURI,
+		sourceFile:  report.SourceFile,
+		line:        report.LineNumber,
+		column:      report.ColumnNumber,
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if last, ok := h.seen[key]; ok && now.Sub(last) < h.options.DedupWindow {
+		return false
+	}
+	if len(h.seen) >= maxSeenReports {
+		for k, last := range h.seen {
+			if now.Sub(last) >= h.options.DedupWindow {
+				delete(h.seen, k)
+			}
+		}
+		if len(h.seen) >= maxSeenReports {
+			//everything is recent, a flood of distinct reports is let through rather than growing without bound
+			return true
+		}
+	}
+	h.seen[key] = now
+	return true
+}
+
+func parseLegacyCSPReport(body []byte, userAgent string) ([]CSPReport, error) {
+	var legacy legacyCSPReport
+	if err := json.Unmarshal(body, &legacy); err != nil {
+		return nil, err
+	}
+	if legacy.Report == nil {
+		return nil, errors.New("no csp-report object")
+	}
+	l := legacy.Report
+	directive := l.EffectiveDirective
+	if directive == "" {
+		//older browsers only send the violated directive with its sources
+		directive, _, _ = strings.Cut(l.ViolatedDirective, " ")
+	}
+	report := CSPReport{
+		DocumentURI:        l.DocumentURI,
+		Referrer:           l.Referrer,
+		BlockedURI:         l.BlockedURI,
+		EffectiveDirective: directive,
+		OriginalPolicy:     l.OriginalPolicy,
+		Disposition:        l.Disposition,
+		SourceFile:         l.SourceFile,
+		LineNumber:         numberOf(l.LineNumber),
+		ColumnNumber:       numberOf(l.ColumnNumber),
+		StatusCode:         numberOf(l.StatusCode),
+		Sample:             l.ScriptSample,
+		UserAgent:          userAgent,
+	}
+	if err := validateCSPReport(report); err != nil {
+		return nil, err
+	}
+	return []CSPReport{report}, nil
+}
+
+func parseReportingAPIReports(body []byte, maxReports int) ([]CSPReport, error) {
+	var batch []reportingAPIReport
+	if err := json.Unmarshal(body, &batch); err != nil {
+		return nil, err
+	}
+	var reports []CSPReport
+	for _, entry := range batch {
+		//a shared endpoint also receives other report types like deprecation reports
+		if entry.Type != "csp-violation" {
+			continue
+		}
+		if len(reports) == maxReports {
+			break
+		}
+		b := entry.Body
+		documentURI := b.DocumentURL
+		if documentURI == "" {
+			documentURI = entry.URL
+		}
+		report := CSPReport{
+			DocumentURI:        documentURI,
+			Referrer:           b.Referrer,
+			BlockedURI:         b.BlockedURL,
+			EffectiveDirective: b.EffectiveDirective,
+			OriginalPolicy:     b.OriginalPolicy,
+			Disposition:        b.Disposition,
+			SourceFile:         b.SourceFile,
+			LineNumber:         numberOf(b.LineNumber),
+			ColumnNumber:       numberOf(b.ColumnNumber),
+			StatusCode:         numberOf(b.StatusCode),
+			Sample:             b.Sample,
+			UserAgent:          entry.UserAgent,
+		}
+		if err := validateCSPReport(report); err != nil {
+			return nil, err
+		}
+		reports = append(reports, report)
+	}
+	return reports, nil
+}
+
+func validateCSPReport(report CSPReport) error {
+	if report.DocumentURI == "" {
+		return errors.New("report without document uri")
+	}
+	if report.EffectiveDirective == "" {
+		return errors.New("report without directive")
+	}
+	return nil
+}
+
+//numberOf returns the integer of a JSON number, 0 if it is missing or not an integer
+func numberOf(n json.Number) int {
+	i, err := n.Int64()
+	if err != nil {
+		return 0
+	}
+	return int(i)
+}
+
+//NewLogReportSink func returns a sink writing every report to logger, the standard logger if nil
+func NewLogReportSink(logger *log.Logger) CSPReportSink {
+	if logger == nil {
+		logger = log.Default()
+	}
+	return CSPReportSinkFunc(func(report CSPReport) {
+		logger.Printf("csp violation: %s blocked %q on %s (%s:%d:%d)", report.EffectiveDirective, report.BlockedURI,
+			report.DocumentURI, report.SourceFile, report.LineNumber, report.ColumnNumber)
+	})
+}
+
+//FileReportSink struct appends every report to a file as one JSON object per line
+type FileReportSink struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+//NewFileReportSink func opens the file for appending, creating it if needed
+func NewFileReportSink(path string) (*FileReportSink, error) {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &FileReportSink{file: file}, nil
+}
+
+//Report func writes the report as a line of JSON, write errors are logged
+func (s *FileReportSink) Report(report CSPReport) {
+	line, err := json.Marshal(report)
+	if err != nil {
+		log.Println("csp report sink:", err)
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.file.Write(append(line, '\n')); err != nil {
+		log.Println("csp report sink:", err)
+	}
+}
+
+//Close func closes the file
+func (s *FileReportSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.file.Close()
+}
+
+//MemoryReportSink struct keeps the latest reports in memory and serves a summary of them as JSON
+type MemoryReportSink struct {
+	mu      sync.Mutex
+	max     int
+	reports []CSPReport
+	total   int
+}
+
+//CSPReportSummary struct counts the reports kept by a MemoryReportSink
+type CSPReportSummary struct {
+	//Total counts every report received, Kept the ones still in memory which the other counts cover
+	Total       int            `json:"total"`
+	Kept        int            `json:"kept"`
+	ByDirective map[string]int `json:"byDirective"`
+	ByBlocked   map[string]int `json:"byBlocked"`
+	ByDocument  map[string]int `json:"byDocument"`
+	//Latest holds the newest reports, the newest first
+	Latest []CSPReport `json:"latest"`
+}
+
+//NewMemoryReportSink func creates a sink keeping the latest max reports, 1000 if max is not positive
+func NewMemoryReportSink(max int) *MemoryReportSink {
+	if max <= 0 {
+		max = 1000
+	}
+	return &MemoryReportSink{max: max}
+}
+
+//Report func keeps the report, dropping the oldest one when the sink is full
+func (s *MemoryReportSink) Report(report CSPReport) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.total++
+	if len(s.reports) == s.max {
+		copy(s.reports, s.reports[1:])
+		s.reports = s.reports[:len(s.reports)-1]
+	}
+	s.reports = append(s.reports, report)
+}
+
+//Reports func returns the kept reports, the oldest first
+func (s *MemoryReportSink) Reports() []CSPReport {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]CSPReport(nil), s.reports...)
+}
+
+//Summary func counts the kept reports by directive, blocked URI and document and lists the latest ones
+func (s *MemoryReportSink) Summary(latest int) CSPReportSummary {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	summary := CSPReportSummary{
+		Total:       s.total,
+		Kept:        len(s.reports),
+		ByDirective: map[string]int{},
+		ByBlocked:   map[string]int{},
+		ByDocument:  map[string]int{},
+		Latest:      []CSPReport{},
+	}
+	for _, report := range s.reports {
+		summary.ByDirective[report.EffectiveDirective]++
+		summary.ByBlocked[report.BlockedURI]++
+		summary.ByDocument[report.DocumentURI]++
+	}
+	for i := len(s.reports) - 1; i >= 0 && len(summary.Latest) < latest; i-- {
+		summary.Latest = append(summary.Latest, s.reports[i])
+	}
+	return summary
+}
+
+//ServeHTTP func serves the summary with the 10 latest reports as JSON, mount it behind authentication
+func (s *MemoryReportSink) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.Summary(10))
+}
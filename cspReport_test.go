@@ -0,0 +1,139 @@
+package goat
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const legacyReport = `{"csp-report": {
+	"document-uri": "https://example.com/page",
+	"referrer": "",
+	"blocked-uri": "https://evil.example/x.js",
+	"violated-directive": "script-src 'self'",
+	"original-policy": "script-src 'self'; report-uri /csp",
+	"disposition": "enforce",
+	"source-file": "https://example.com/page",
+	"line-number": 12,
+	"column-number": 4,
+	"status-code": 200
+}}`
+
+const reportingAPIBatch = `[
+	{"type": "csp-violation", "age": 10, "url": "https://example.com/app", "user_agent": "Browser/1.0",
+	 "body": {"documentURL": "https://example.com/app", "blockedURL": "inline", "effectiveDirective": "style-src-elem",
+	          "disposition": "report", "sample": "body{}", "lineNumber": 3}},
+	{"type": "deprecation", "url": "https://example.com/app", "body": {"id": "x"}}
+]`
+
+//reportHeader returns the headers a legacy browser sends with a report
+func reportHeader(contentType string) http.Header {
+	return http.Header{"Content-Type": {contentType}, "User-Agent": {"Legacy/2.0"}}
+}
+
+func Test_CSPReportHandler(t *testing.T) {
+	var reports []CSPReport
+	h := NewCSPReportHandler(CSPReportSinkFunc(func(report CSPReport) {
+		reports = append(reports, report)
+	}), CSPReportOptions{})
+
+	assert.Equal(t, http.StatusNoContent, serve(h, "POST", "/csp", strings.NewReader(legacyReport), reportHeader("application/csp-report")).Code, "Legacy report not accepted")
+	assert.Equal(t, http.StatusNoContent, serve(h, "POST", "/csp", strings.NewReader(reportingAPIBatch), reportHeader("application/reports+json")).Code, "Batch not accepted")
+	if assert.Len(t, reports, 2, "Reports not passed to the sink") {
+		legacy := reports[0]
+		assert.Equal(t, "https://example.com/page", legacy.DocumentURI)
+		assert.Equal(t, "script-src", legacy.EffectiveDirective, "Directive not taken from violated-directive")
+		assert.Equal(t, 12, legacy.LineNumber)
+		assert.Equal(t, "Legacy/2.0", legacy.UserAgent)
+		assert.False(t, legacy.Received.IsZero(), "Received not set")
+
+		batched := reports[1]
+		assert.Equal(t, "style-src-elem", batched.EffectiveDirective)
+		assert.Equal(t, "inline", batched.BlockedURI)
+		assert.Equal(t, "report", batched.Disposition)
+		assert.Equal(t, "Browser/1.0", batched.UserAgent)
+	}
+
+	serve(h, "POST", "/csp", strings.NewReader(legacyReport), reportHeader("application/csp-report"))
+	assert.Len(t, reports, 2, "Repeated report not dropped")
+}
+
+func Test_CSPReportHandler_Errors(t *testing.T) {
+	h := NewCSPReportHandler(CSPReportSinkFunc(func(CSPReport) {}), CSPReportOptions{MaxBodySize: 512})
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest("GET", "/csp", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, rr.Code, "GET accepted")
+	assert.Equal(t, "POST", rr.Header().Get("Allow"))
+
+	assert.Equal(t, http.StatusUnsupportedMediaType, serve(h, "POST", "/csp", strings.NewReader(legacyReport), reportHeader("text/plain")).Code, "Media type accepted")
+	assert.Equal(t, http.StatusRequestEntityTooLarge, serve(h, "POST", "/csp", strings.NewReader(strings.Repeat(" ", 600)), reportHeader("application/csp-report")).Code, "Large body accepted")
+	assert.Equal(t, http.StatusBadRequest, serve(h, "POST", "/csp", strings.NewReader(`{"csp-report": `), reportHeader("application/csp-report")).Code, "Malformed JSON accepted")
+	assert.Equal(t, http.StatusBadRequest, serve(h, "POST", "/csp", strings.NewReader(`{"other": {}}`), reportHeader("application/csp-report")).Code, "Report without csp-report accepted")
+	assert.Equal(t, http.StatusBadRequest, serve(h, "POST", "/csp", strings.NewReader(`{"csp-report": {"blocked-uri": "x"}}`), reportHeader("application/csp-report")).Code, "Report without document accepted")
+}
+
+func Test_CSPReportHandler_Dedup(t *testing.T) {
+	count := 0
+	h := NewCSPReportHandler(CSPReportSinkFunc(func(CSPReport) { count++ }), CSPReportOptions{DedupWindow: -1})
+	serve(h, "POST", "/csp", strings.NewReader(legacyReport), reportHeader("application/csp-report"))
+	serve(h, "POST", "/csp", strings.NewReader(legacyReport), reportHeader("application/csp-report"))
+	assert.Equal(t, 2, count, "Report dropped without dedup")
+
+	h = NewCSPReportHandler(CSPReportSinkFunc(func(CSPReport) { count++ }), CSPReportOptions{DedupWindow: time.Millisecond})
+	count = 0
+	serve(h, "POST", "/csp", strings.NewReader(legacyReport), reportHeader("application/csp-report"))
+	time.Sleep(2 * time.Millisecond)
+	serve(h, "POST", "/csp", strings.NewReader(legacyReport), reportHeader("application/csp-report"))
+	assert.Equal(t, 2, count, "Report dropped after the window")
+}
+
+func Test_MemoryReportSink(t *testing.T) {
+	sink := NewMemoryReportSink(2)
+	sink.Report(CSPReport{DocumentURI: "/a", EffectiveDirective: "script-src", BlockedURI: "inline"})
+	sink.Report(CSPReport{DocumentURI: "/b", EffectiveDirective: "script-src", BlockedURI: "eval"})
+	sink.Report(CSPReport{DocumentURI: "/b", EffectiveDirective: "img-src", BlockedURI: "http://x"})
+	assert.Len(t, sink.Reports(), 2, "Oldest report not dropped")
+
+	rr := httptest.NewRecorder()
+	sink.ServeHTTP(rr, httptest.NewRequest("GET", "/csp/summary", nil))
+	var summary CSPReportSummary
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &summary))
+	assert.Equal(t, 3, summary.Total)
+	assert.Equal(t, 2, summary.Kept)
+	assert.Equal(t, map[string]int{"script-src": 1, "img-src": 1}, summary.ByDirective)
+	assert.Equal(t, map[string]int{"/b": 2}, summary.ByDocument)
+	if assert.Len(t, summary.Latest, 2) {
+		assert.Equal(t, "img-src", summary.Latest[0].EffectiveDirective, "Newest report not first")
+	}
+}
+
+func Test_FileReportSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "csp.jsonl")
+	sink, err := NewFileReportSink(path)
+	assert.NoError(t, err)
+	h := NewCSPReportHandler(sink, CSPReportOptions{})
+	serve(h, "POST", "/csp", strings.NewReader(legacyReport), reportHeader("application/csp-report"))
+	serve(h, "POST", "/csp", strings.NewReader(reportingAPIBatch), reportHeader("application/reports+json"))
+	assert.NoError(t, sink.Close())
+
+	f, err := os.Open(path)
+	assert.NoError(t, err)
+	defer f.Close()
+	var directives []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var report CSPReport
+		assert.NoError(t, json.Unmarshal(scanner.Bytes(), &report))
+		directives = append(directives, report.EffectiveDirective)
+	}
+	assert.Equal(t, []string{"script-src", "style-src-elem"}, directives, "Reports not written as JSON lines")
+}
@@ -1,18 +1,18 @@
 package goat
 
 import (
+	"errors"
 	"net/http"
 	"net/http/httptest"
+	"sync"
 	"testing"
 
-	"log"
-
 	"github.com/stretchr/testify/assert"
 )
 
 func Test_CSP(t *testing.T) {
 	h := &TestNoCacheHandler{}
-	csp := NewCSP(CSPOptions{
+	csp, err := NewCSP(CSPOptions{
 		DefaultSrc:     []string{"'self'", "s1.rdbuz.com"},
 		ScriptSrc:      []string{"'self'"},
 		StyleSrc:       []string{"'self'"},
@@ -29,6 +29,7 @@ func Test_CSP(t *testing.T) {
 		ReportURI:      "/some-dummy-report-api",
 		IsReportOnly:   true,
 	})
+	assert.NoError(t, err)
 	server := httptest.NewServer(csp.CSP(h))
 	defer server.Close()
 
@@ -37,13 +38,121 @@ func Test_CSP(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	if !csp.cspOptions.IsReportOnly {
-		log.Println(resp.Header.Get("Content-Security-Policy"))
-		assert.Contains(t, resp.Header.Get("Content-Security-Policy"), "default-src 'self' s1.rdbuz.com", "CSP not working")
-		assert.Contains(t, resp.Header.Get("Content-Security-Policy"), "script-src 'self'", "CSP not working")
-		assert.Contains(t, resp.Header.Get("Content-Security-Policy"), "style-src 'self'", "CSP not working")
-	} else {
-		log.Println(resp.Header.Get("Content-Security-Policy-Report-Only"))
-		assert.Contains(t, resp.Header.Get("Content-Security-Policy-Report-Only"), "report-uri /some-dummy-report-api", "CSP Report Only not working")
+	assert.Equal(t, "", resp.Header.Get("Content-Security-Policy"), "Enforced policy sent in report only mode")
+	assert.Equal(t, "Content-Security-Policy-Report-Only", csp.HeaderName(), "Header name does not match")
+	assert.Equal(t, "default-src 'self' s1.rdbuz.com; script-src 'self'; style-src 'self'; img-src 'self'; "+
+		"connect-src 'self'; font-src 'self'; object-src 'self'; media-src 'self'; child-src 'self'; "+
+		"form-action 'self'; frame-ancestors 'none'; "+
+		"sandbox allow-forms allow-scripts; report-uri /some-dummy-report-api",
+		resp.Header.Get("Content-Security-Policy-Report-Only"), "CSP Report Only not working")
+	assert.Equal(t, csp.HeaderValue(), resp.Header.Get("Content-Security-Policy-Report-Only"), "HeaderValue does not match the header")
+}
+
+func Test_CSP_Enforced(t *testing.T) {
+	csp, err := NewCSP(CSPOptions{
+		DefaultSrc: []string{"'self'", "s1.rdbuz.com"},
+		ScriptSrc:  []string{"'self'", "https://cdn.example.com/app.js?v=1&x=<y>"},
+	})
+	assert.NoError(t, err)
+	rr := httptest.NewRecorder()
+	csp.CSP(&TestNoCacheHandler{}).ServeHTTP(rr, httptest.NewRequest("GET", "/", nil))
+	assert.Equal(t, "default-src 'self' s1.rdbuz.com; script-src 'self' https://cdn.example.com/app.js?v=1&x=<y>",
+		rr.Header().Get("Content-Security-Policy"), "Policy not enforced")
+
+	_, err = NewCSP(CSPOptions{DefaultSrc: []string{"'self'"}, IsReportOnly: true})
+	var cspErr *CSPError
+	if assert.True(t, errors.As(err, &cspErr), "Report only policy without endpoint accepted") {
+		assert.Equal(t, "report-uri", cspErr.Directive)
 	}
 }
+
+func Test_CSP_Concurrent(t *testing.T) {
+	csp, err := NewCSP(CSPOptions{DefaultSrc: []string{"'self'"}, ImgSrc: []string{"*"}})
+	assert.NoError(t, err)
+	handler := csp.CSP(&TestNoCacheHandler{})
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, httptest.NewRequest("GET", "/", nil))
+			assert.Equal(t, "default-src 'self'; img-src *", rr.Header().Get("Content-Security-Policy"), "Policy changed between requests")
+		}()
+	}
+	wg.Wait()
+}
+
+func Test_NewCSP_Errors(t *testing.T) {
+	tests := []struct {
+		options   CSPOptions
+		directive string
+	}{
+		{CSPOptions{}, "policy"},
+		{CSPOptions{ScriptSrc: []string{"'self'; img-src *"}}, "script-src"},
+		{CSPOptions{ScriptSrc: []string{"self"}}, "script-src"},
+		{CSPOptions{ImgSrc: []string{""}}, "img-src"},
+		{CSPOptions{ObjectSrc: []string{"'none'", "'self'"}}, "object-src"},
+		{CSPOptions{DefaultSrc: []string{"a.com,b.com"}}, "default-src"},
+		{CSPOptions{Sandbox: []string{"allow-everything"}}, "sandbox"},
+		{CSPOptions{PluginTypes: []string{"application/pdf"}}, "policy"},
+		{CSPOptions{RequireTrustedTypesFor: []string{"script"}}, "require-trusted-types-for"},
+		{CSPOptions{TrustedTypes: []string{CSPNone, "app"}}, "trusted-types"},
+		{CSPOptions{Directives: map[string][]string{"Fenced-Frame-Src": nil}}, "Fenced-Frame-Src"},
+		{CSPOptions{Directives: map[string][]string{"script-src": {CSPSelf}}}, "script-src"},
+		{CSPOptions{Directives: map[string][]string{"report-to": {"csp"}}}, "report-to"},
+		{CSPOptions{DefaultSrc: []string{CSPSelf}, ReportTo: "csp endpoint"}, "report-to"},
+		{CSPOptions{DefaultSrc: []string{"'self'"}, ReportURI: "/report endpoint"}, "report-uri"},
+	}
+	for _, tt := range tests {
+		_, err := NewCSP(tt.options)
+		var cspErr *CSPError
+		if assert.True(t, errors.As(err, &cspErr), "No CSPError for %+v: %v", tt.options, err) {
+			assert.Equal(t, tt.directive, cspErr.Directive, "Directive does not match for %+v", tt.options)
+		}
+	}
+}
+
+func Test_CSP_Level3(t *testing.T) {
+	csp, err := NewCSP(CSPOptions{
+		DefaultSrc:              []string{CSPSelf},
+		ScriptSrc:               []string{CSPSelf, CSPStrictDynamic},
+		ScriptSrcElem:           []string{CSPSelf},
+		ScriptSrcAttr:           []string{CSPUnsafeHashes},
+		StyleSrcElem:            []string{CSPSelf},
+		StyleSrcAttr:            []string{CSPNone},
+		FrameSrc:                []string{"https://player.example.com"},
+		WorkerSrc:               []string{CSPSelf, "blob:"},
+		ManifestSrc:             []string{CSPSelf},
+		BaseURI:                 []string{CSPNone},
+		ObjectSrc:               []string{CSPNone},
+		PluginTypes:             []string{"application/pdf"},
+		UpgradeInsecureRequests: true,
+		BlockAllMixedContent:    true,
+		RequireTrustedTypesFor:  []string{CSPScript},
+		TrustedTypes:            []string{"app", "dompurify", "'allow-duplicates'"},
+		Directives: map[string][]string{
+			"webrtc":           {"'block'"},
+			"fenced-frame-src": {"https://ads.example.com"},
+		},
+		ReportURI:    "/csp-reports",
+		ReportTo:     "csp",
+		IsReportOnly: true,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "Content-Security-Policy-Report-Only", csp.HeaderName())
+	assert.Equal(t, "default-src 'self'; script-src 'self' 'strict-dynamic'; script-src-elem 'self'; script-src-attr 'unsafe-hashes'; "+
+		"style-src-elem 'self'; style-src-attr 'none'; object-src 'none'; frame-src https://player.example.com; "+
+		"worker-src 'self' blob:; manifest-src 'self'; base-uri 'none'; upgrade-insecure-requests; block-all-mixed-content; "+
+		"require-trusted-types-for 'script'; trusted-types app dompurify 'allow-duplicates'; "+
+		"fenced-frame-src https://ads.example.com; webrtc 'block'; report-uri /csp-reports; report-to csp",
+		csp.HeaderValue(), "Level 3 policy does not match")
+
+	reportTo, err := NewCSP(CSPOptions{DefaultSrc: []string{CSPSelf}, ReportTo: "csp", IsReportOnly: true})
+	assert.NoError(t, err)
+	assert.Equal(t, "Content-Security-Policy-Report-Only", reportTo.HeaderName(), "report-to not enough for report only")
+
+	valueless, err := NewCSP(CSPOptions{Directives: map[string][]string{"upgrade-insecure-requests-v2": nil}})
+	assert.NoError(t, err)
+	assert.Equal(t, "upgrade-insecure-requests-v2", valueless.HeaderValue(), "Directive without values does not match")
+}
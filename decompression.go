@@ -0,0 +1,203 @@
+package goat
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+//Decoder is implemented by the encoders which can also read their coding, Decompression decodes request bodies with them
+type Decoder interface {
+	//NewReader returns a reader decoding r, it fails if r does not start like the coding
+	NewReader(r io.Reader) (io.ReadCloser, error)
+}
+
+//NewReader func returns a gzip.Reader
+func (GzipEncoder) NewReader(r io.Reader) (io.ReadCloser, error) {
+	return gzip.NewReader(r)
+}
+
+//NewReader func returns a zlib reader
+func (DeflateEncoder) NewReader(r io.Reader) (io.ReadCloser, error) {
+	return zlib.NewReader(r)
+}
+
+//DefaultMaxDecompressedSize is the limit of a decoded request body used when DecompressionOptions has none
+const DefaultMaxDecompressedSize = 10 << 20
+
+//DecompressionOptions struct configures a Decompressor
+type DecompressionOptions struct {
+	//MaxSize is the largest decoded body in bytes, reading beyond it fails with a 413 HTTPError.
+	//0 stands for DefaultMaxDecompressedSize
+	MaxSize int64
+}
+
+//Decompressor struct decodes compressed request bodies
+type Decompressor struct {
+	maxSize int64
+}
+
+//NewDecompressor func creates a Decompressor, it decodes every coding whose registered encoder is a Decoder
+func NewDecompressor(options DecompressionOptions) *Decompressor {
+	maxSize := options.MaxSize
+	if maxSize <= 0 {
+		maxSize = DefaultMaxDecompressedSize
+	}
+	return &Decompressor{
+		maxSize: maxSize,
+	}
+}
+
+//Decompression middleware decodes request bodies with the default options
+func Decompression(next http.Handler) http.Handler {
+	return NewDecompressor(DecompressionOptions{}).Decompression(next)
+}
+
+//Decompression middleware decodes request bodies sent with a Content-Encoding and removes the header,
+//so handlers read plain bodies. Unknown codings are answered with 415 and malformed bodies with 400.
+//A body decoding to more than the max size fails the read with a 413 HTTPError, which is sent to the client
+//if the handler did not write a response itself
+func (d *Decompressor) Decompression(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		codings := contentCodings(r.Header.Get("Content-Encoding"))
+		if len(codings) == 0 || r.Body == nil || r.Body == http.NoBody {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		decoders := decodersByCoding()
+		body := r.Body
+		var closers []io.Closer
+		//codings are listed in the order they were applied, so they are undone from the last one
+		for i := len(codings) - 1; i >= 0; i-- {
+			decoder, ok := decoders[codings[i]]
+			if !ok {
+				w.Header().Set("Accept-Encoding", strings.Join(decodableCodings(), ", "))
+				WriteError(w, r, NewHTTPError(http.StatusUnsupportedMediaType, fmt.Sprintf("unsupported content encoding %q", codings[i])))
+				return
+			}
+			reader, err := decoder.NewReader(body)
+			if err != nil {
+				WriteError(w, r, &HTTPError{
+					Status:  http.StatusBadRequest,
+					Message: fmt.Sprintf("malformed %s request body", codings[i]),
+					Err:     err,
+				})
+				return
+			}
+			closers = append(closers, reader)
+			body = reader
+		}
+
+		limited := &maxSizeReader{
+			reader:    body,
+			remaining: d.maxSize,
+			maxSize:   d.maxSize,
+		}
+		//the next handler gets a copy, the request of the caller keeps its body and headers
+		decoded := r.Clone(r.Context())
+		decoded.Body = &decodedBody{
+			Reader:  limited,
+			closers: append(closers, r.Body),
+		}
+		decoded.Header.Del("Content-Encoding")
+		decoded.Header.Del("Content-Length")
+		decoded.ContentLength = -1
+
+		nrw := NewResponseWriter(w)
+		defer nrw.Finish()
+		next.ServeHTTP(nrw, decoded)
+		if limited.exceeded && !nrw.Written() {
+			WriteError(nrw, decoded, limited.err())
+		}
+	})
+}
+
+//contentCodings returns the lowercased codings of a Content-Encoding header without identity
+func contentCodings(header string) []string {
+	var codings []string
+	for _, coding := range strings.Split(header, ",") {
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		if coding != "" && coding != "identity" {
+			codings = append(codings, coding)
+		}
+	}
+	return codings
+}
+
+func decodersByCoding() map[string]Decoder {
+	decoders := map[string]Decoder{}
+	for _, e := range registeredEncoders() {
+		if decoder, ok := e.(Decoder); ok {
+			decoders[strings.ToLower(e.Encoding())] = decoder
+		}
+	}
+	return decoders
+}
+
+//decodableCodings returns the codings Decompression can decode, the server preference first
+func decodableCodings() []string {
+	var codings []string
+	for _, e := range registeredEncoders() {
+		if _, ok := e.(Decoder); ok {
+			codings = append(codings, e.Encoding())
+		}
+	}
+	return codings
+}
+
+//ErrBodyTooLarge is wrapped by the 413 HTTPError returned when a decoded request body exceeds the max size
+var ErrBodyTooLarge = errors.New("request body too large")
+
+//maxSizeReader fails once more than maxSize bytes were read
+type maxSizeReader struct {
+	reader    io.Reader
+	remaining int64
+	maxSize   int64
+	exceeded  bool
+}
+
+func (m *maxSizeReader) Read(p []byte) (int, error) {
+	if m.exceeded {
+		return 0, m.err()
+	}
+	//read one byte more than allowed to tell a body of exactly maxSize from a larger one
+	if int64(len(p)) > m.remaining+1 {
+		p = p[:m.remaining+1]
+	}
+	n, err := m.reader.Read(p)
+	if int64(n) > m.remaining {
+		m.exceeded = true
+		return int(m.remaining), m.err()
+	}
+	m.remaining -= int64(n)
+	return n, err
+}
+
+func (m *maxSizeReader) err() error {
+	return &HTTPError{
+		Status:  http.StatusRequestEntityTooLarge,
+		Message: fmt.Sprintf("decompressed request body exceeds %d bytes", m.maxSize),
+		Err:     ErrBodyTooLarge,
+	}
+}
+
+//decodedBody closes the decoders and the original body
+type decodedBody struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (b *decodedBody) Close() error {
+	var errs []error
+	for _, c := range b.closers {
+		if err := c.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,98 @@
+package goat
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func gzipped(s string) []byte {
+	buf := &bytes.Buffer{}
+	gz := gzip.NewWriter(buf)
+	gz.Write([]byte(s))
+	gz.Close()
+	return buf.Bytes()
+}
+
+//echoHandler answers with the request body and its Content-Encoding
+var echoHandler = HandlerE(func(w http.ResponseWriter, r *http.Request) error {
+	b, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return err
+	}
+	w.Header().Set("X-Content-Encoding", r.Header.Get("Content-Encoding"))
+	w.Write(b)
+	return nil
+})
+
+func Test_Decompression(t *testing.T) {
+	handler := Decompression(echoHandler)
+
+	rr := serve(handler, "POST", "/", bytes.NewReader(gzipped(`{"name":"goat"}`)), http.Header{"Content-Encoding": {"gzip"}})
+	assert.Equal(t, http.StatusOK, rr.Code, "Status does not match")
+	assert.Equal(t, `{"name":"goat"}`, rr.Body.String(), "Body not decoded")
+	assert.Equal(t, "", rr.Header().Get("X-Content-Encoding"), "Content-Encoding not removed")
+
+	deflated := &bytes.Buffer{}
+	zw := zlib.NewWriter(deflated)
+	zw.Write(gzipped("twice"))
+	zw.Close()
+	rr = serve(handler, "POST", "/", bytes.NewReader(deflated.Bytes()), http.Header{"Content-Encoding": {"gzip, deflate"}})
+	assert.Equal(t, "twice", rr.Body.String(), "Stacked codings not decoded")
+
+	rr = serve(handler, "POST", "/", bytes.NewReader([]byte("plain")), http.Header{"Content-Encoding": {""}})
+	assert.Equal(t, "plain", rr.Body.String(), "Plain body changed")
+}
+
+func Test_Decompression_Errors(t *testing.T) {
+	handler := Decompression(echoHandler)
+
+	rr := serve(handler, "POST", "/", bytes.NewReader([]byte("data")), http.Header{"Content-Encoding": {"br"}})
+	assert.Equal(t, http.StatusUnsupportedMediaType, rr.Code, "Unknown coding accepted")
+	assert.Equal(t, "gzip, deflate", rr.Header().Get("Accept-Encoding"), "Supported codings not announced")
+	assert.True(t, strings.Contains(rr.Body.String(), `unsupported content encoding "br"`), "Error message does not match")
+
+	rr = serve(handler, "POST", "/", bytes.NewReader([]byte("not gzip")), http.Header{"Content-Encoding": {"gzip"}})
+	assert.Equal(t, http.StatusBadRequest, rr.Code, "Malformed body accepted")
+}
+
+func Test_Decompression_MaxSize(t *testing.T) {
+	d := NewDecompressor(DecompressionOptions{MaxSize: 10})
+
+	rr := serve(d.Decompression(echoHandler), "POST", "/", bytes.NewReader(gzipped("0123456789")), http.Header{"Content-Encoding": {"gzip"}})
+	assert.Equal(t, "0123456789", rr.Body.String(), "Body of max size refused")
+
+	rr = serve(d.Decompression(echoHandler), "POST", "/", bytes.NewReader(gzipped(strings.Repeat("0", 1<<20))), http.Header{"Content-Encoding": {"gzip"}})
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rr.Code, "Zip bomb accepted")
+	assert.True(t, strings.Contains(rr.Body.String(), "exceeds 10 bytes"), "Error message does not match")
+
+	//a handler which ignores the read error still gets the 413 sent
+	var readErr error
+	ignoring := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, readErr = io.Copy(ioutil.Discard, r.Body)
+	})
+	rr = serve(d.Decompression(ignoring), "POST", "/", bytes.NewReader(gzipped(strings.Repeat("0", 100))), http.Header{"Content-Encoding": {"gzip"}})
+	assert.True(t, errors.Is(readErr, ErrBodyTooLarge), "Read error does not match")
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rr.Code, "413 not sent")
+}
+
+func Test_Decompression_KeepsRequest(t *testing.T) {
+	body := ioutil.NopCloser(bytes.NewReader(gzipped("hello")))
+	req := httptest.NewRequest("POST", "/", body)
+	req.Header.Set("Content-Encoding", "gzip")
+	req.ContentLength = 25
+	Decompression(echoHandler).ServeHTTP(httptest.NewRecorder(), req)
+
+	assert.Equal(t, body, req.Body, "Body of the caller replaced")
+	assert.Equal(t, "gzip", req.Header.Get("Content-Encoding"), "Headers of the caller changed")
+	assert.Equal(t, int64(25), req.ContentLength, "ContentLength of the caller changed")
+}
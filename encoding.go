@@ -0,0 +1,165 @@
+package goat
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+//DefaultCompression asks an Encoder for its default level, it has the value of gzip.DefaultCompression
+const DefaultCompression = gzip.DefaultCompression
+
+//Encoder creates the writers of one content coding, like gzip. Register more codings like br or zstd with RegisterEncoder
+type Encoder interface {
+	//Encoding returns the token used in the Accept-Encoding and Content-Encoding headers, e.g. gzip
+	Encoding() string
+	//NewWriter returns a writer compressing into w with the given level, DefaultCompression stands for the default of the coding
+	NewWriter(w io.Writer, level int) (EncodeWriter, error)
+}
+
+//EncodeWriter is a compressing writer which can be reused for another response after Reset
+type EncodeWriter interface {
+	io.WriteCloser
+	//Flush writes the data compressed so far to the underlying writer
+	Flush() error
+	//Reset discards the state of the writer and makes it write into w
+	Reset(w io.Writer)
+}
+
+//GzipEncoder is the Encoder of the gzip coding
+type GzipEncoder struct{}
+
+//Encoding func returns gzip
+func (GzipEncoder) Encoding() string {
+	return "gzip"
+}
+
+//NewWriter func returns a gzip.Writer
+func (GzipEncoder) NewWriter(w io.Writer, level int) (EncodeWriter, error) {
+	return gzip.NewWriterLevel(w, level)
+}
+
+//DeflateEncoder is the Encoder of the deflate coding, which http defines as zlib framed deflate data
+type DeflateEncoder struct{}
+
+//Encoding func returns deflate
+func (DeflateEncoder) Encoding() string {
+	return "deflate"
+}
+
+//NewWriter func returns a zlib.Writer
+func (DeflateEncoder) NewWriter(w io.Writer, level int) (EncodeWriter, error) {
+	return zlib.NewWriterLevel(w, level)
+}
+
+//encoderRegistry holds the encoders in the order the server prefers them
+var encoderRegistry = struct {
+	mu       sync.RWMutex
+	encoders []Encoder
+}{
+	encoders: []Encoder{GzipEncoder{}, DeflateEncoder{}},
+}
+
+//RegisterEncoder func makes a content coding available to the compression middlewares created afterwards.
+//The encoder becomes the one the server prefers most when the client accepts several codings equally,
+//an encoder registered earlier for the same coding is replaced
+func RegisterEncoder(encoder Encoder) {
+	encoderRegistry.mu.Lock()
+	defer encoderRegistry.mu.Unlock()
+	encoders := []Encoder{encoder}
+	for _, e := range encoderRegistry.encoders {
+		if !strings.EqualFold(e.Encoding(), encoder.Encoding()) {
+			encoders = append(encoders, e)
+		}
+	}
+	encoderRegistry.encoders = encoders
+}
+
+//Encoders func returns the registered content codings, the one the server prefers most first
+func Encoders() []string {
+	var names []string
+	for _, e := range registeredEncoders() {
+		names = append(names, e.Encoding())
+	}
+	return names
+}
+
+func registeredEncoders() []Encoder {
+	encoderRegistry.mu.RLock()
+	defer encoderRegistry.mu.RUnlock()
+	return append([]Encoder(nil), encoderRegistry.encoders...)
+}
+
+//parseAcceptEncoding returns the q-value of every coding in an Accept-Encoding header, with the codings lowercased.
+//Codings without a valid q-value get 1
+func parseAcceptEncoding(header string) map[string]float64 {
+	accepted := map[string]float64{}
+	for _, part := range strings.Split(header, ",") {
+		params := strings.Split(part, ";")
+		coding := strings.ToLower(strings.TrimSpace(params[0]))
+		if coding == "" {
+			continue
+		}
+		q := 1.0
+		for _, param := range params[1:] {
+			name, value, ok := strings.Cut(strings.TrimSpace(param), "=")
+			if !ok || !strings.EqualFold(strings.TrimSpace(name), "q") {
+				continue
+			}
+			if parsed, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil && parsed >= 0 && parsed <= 1 {
+				q = parsed
+			}
+		}
+		accepted[coding] = q
+	}
+	return accepted
+}
+
+//negotiateEncoding picks the encoder for an Accept-Encoding header, nil means the response is sent as it is.
+//On a tie the encoder listed first wins
+func negotiateEncoding(header string, encoders []Encoder) Encoder {
+	codings := make([]string, len(encoders))
+	for i, e := range encoders {
+		codings[i] = e.Encoding()
+	}
+	best := negotiateCoding(header, codings)
+	for _, e := range encoders {
+		if best != "" && e.Encoding() == best {
+			return e
+		}
+	}
+	return nil
+}
+
+//negotiateCoding picks one of the codings for an Accept-Encoding header, "" means the response is sent as it is.
+//The coding with the highest q-value wins, on a tie the one listed first in codings.
+//Identity only wins when the client lists it, or *, with a higher q-value than every coding
+func negotiateCoding(header string, codings []string) string {
+	if strings.TrimSpace(header) == "" {
+		return ""
+	}
+	accepted := parseAcceptEncoding(header)
+	wildcard, hasWildcard := accepted["*"]
+	qualityOf := func(coding string) (float64, bool) {
+		if q, ok := accepted[coding]; ok {
+			return q, true
+		}
+		return wildcard, hasWildcard
+	}
+
+	best := ""
+	bestQ := 0.0
+	for _, coding := range codings {
+		q, _ := qualityOf(strings.ToLower(coding))
+		if q > bestQ {
+			best, bestQ = coding, q
+		}
+	}
+	if identityQ, ok := qualityOf("identity"); ok && identityQ > bestQ {
+		return ""
+	}
+	return best
+}
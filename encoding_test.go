@@ -0,0 +1,100 @@
+package goat
+
+import (
+	"compress/zlib"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+//testEncoder is a fake coding which writes the body as it is
+type testEncoder struct{}
+
+func (testEncoder) Encoding() string {
+	return "test"
+}
+
+func (testEncoder) NewWriter(w io.Writer, level int) (EncodeWriter, error) {
+	return &testEncodeWriter{w: w}, nil
+}
+
+type testEncodeWriter struct {
+	w io.Writer
+}
+
+func (e *testEncodeWriter) Write(b []byte) (int, error) { return e.w.Write(b) }
+func (e *testEncodeWriter) Close() error                { return nil }
+func (e *testEncodeWriter) Flush() error                { return nil }
+func (e *testEncodeWriter) Reset(w io.Writer)           { e.w = w }
+
+func encodingOf(header string, encoders ...Encoder) string {
+	if len(encoders) == 0 {
+		encoders = []Encoder{GzipEncoder{}, DeflateEncoder{}}
+	}
+	e := negotiateEncoding(header, encoders)
+	if e == nil {
+		return "identity"
+	}
+	return e.Encoding()
+}
+
+func Test_NegotiateEncoding(t *testing.T) {
+	assert.Equal(t, "identity", encodingOf(""), "Missing header must not be compressed")
+	assert.Equal(t, "gzip", encodingOf("gzip"), "Single coding does not match")
+	assert.Equal(t, "gzip", encodingOf("deflate, gzip"), "Server preference must win a tie")
+	assert.Equal(t, "deflate", encodingOf("gzip;q=0.5, deflate"), "Higher q-value must win")
+	assert.Equal(t, "deflate", encodingOf("gzip;q=0, deflate;q=0.1"), "q=0 must exclude a coding")
+	assert.Equal(t, "identity", encodingOf("gzip;q=0"), "Only refused codings must not be compressed")
+	assert.Equal(t, "gzip", encodingOf("*"), "Wildcard must accept every coding")
+	assert.Equal(t, "deflate", encodingOf("*, gzip;q=0"), "Explicit coding must override the wildcard")
+	assert.Equal(t, "identity", encodingOf("gzip;q=0.5, identity"), "Preferred identity must win")
+	assert.Equal(t, "gzip", encodingOf("GZIP; Q=0.8, identity;q=0.2"), "Codings must be case insensitive")
+	assert.Equal(t, "identity", encodingOf("br"), "Unknown coding must not be compressed")
+	assert.Equal(t, "test", encodingOf("gzip, test", testEncoder{}, GzipEncoder{}), "Encoder order must decide a tie")
+}
+
+func Test_RegisterEncoder(t *testing.T) {
+	defer func() {
+		encoderRegistry.encoders = []Encoder{GzipEncoder{}, DeflateEncoder{}}
+	}()
+	assert.Equal(t, []string{"gzip", "deflate"}, Encoders(), "Default encoders do not match")
+	RegisterEncoder(testEncoder{})
+	RegisterEncoder(testEncoder{})
+	assert.Equal(t, []string{"test", "gzip", "deflate"}, Encoders(), "Registered encoder must be preferred once")
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip, test")
+	Compression(&TestCompressionHandler{}).ServeHTTP(rr, req)
+	assert.Equal(t, "test", rr.Header().Get("Content-Encoding"), "Registered encoder not used")
+	assert.Equal(t, "this is compression test", rr.Body.String(), "Body does not match")
+}
+
+func Test_Compression_Deflate(t *testing.T) {
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip;q=0.1, deflate")
+	Compression(&TestCompressionHandler{}).ServeHTTP(rr, req)
+	resp := rr.Result()
+	assert.Equal(t, "deflate", resp.Header.Get("Content-Encoding"), "Content Encoding does not match")
+
+	reader, err := zlib.NewReader(resp.Body)
+	assert.NoError(t, err)
+	b, _ := ioutil.ReadAll(reader)
+	assert.Equal(t, "this is compression test", string(b), "Body does not match")
+}
+
+func Test_Compression_Identity(t *testing.T) {
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip;q=0")
+	Compression(&TestCompressionHandler{}).ServeHTTP(rr, req)
+	assert.Equal(t, "", rr.Header().Get("Content-Encoding"), "Refused coding used")
+	assert.Equal(t, "Accept-Encoding", rr.Header().Get("Vary"), "Vary header missing")
+	assert.Equal(t, http.StatusOK, rr.Code, "Status does not match")
+	assert.Equal(t, "this is compression test", rr.Body.String(), "Body does not match")
+}
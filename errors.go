@@ -0,0 +1,196 @@
+package goat
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+//HandlerE is a handler which returns an error instead of writing the error response itself,
+//the error is translated to a status code and written the same way Recovery writes a panic
+type HandlerE func(w http.ResponseWriter, r *http.Request) error
+
+//ServeHTTP func makes HandlerE a http.Handler
+//The writer is wrapped in a ResponseWriter unless it is one already, so an error after the response started writes nothing
+func (h HandlerE) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rw, ok := w.(ResponseWriter)
+	if !ok {
+		rw = NewResponseWriter(w)
+		defer rw.Finish()
+	}
+	if err := h(rw, r); err != nil {
+		WriteError(rw, r, err)
+	}
+}
+
+//ThenE func is similar to Then but accepts a HandlerE
+func (mc MiddlewareChain) ThenE(handler HandlerE) http.Handler {
+	return mc.Then(handler)
+}
+
+//StatusCoder is implemented by errors which know the status code they should be answered with,
+//the default translator finds them anywhere in the chain of wrapped errors
+type StatusCoder interface {
+	StatusCode() int
+}
+
+//ClientMessager is implemented by errors whose message is meant for the client,
+//the default translator sends the status text for every other error
+type ClientMessager interface {
+	ClientMessage() string
+}
+
+//HTTPError is an error with the status code and the message to send to the client
+type HTTPError struct {
+	Status  int
+	Message string //sent to the client, the status text is used if empty
+	Err     error  //the underlying error, it is not sent to the client
+}
+
+//Error func describes the error for logs, it includes the underlying error which is never sent to the client
+func (e *HTTPError) Error() string {
+	message := e.ClientMessage()
+	if e.Err != nil {
+		return message + ": " + e.Err.Error()
+	}
+	return message
+}
+
+//ClientMessage func returns the message sent to the client, the status text if no message is set
+func (e *HTTPError) ClientMessage() string {
+	if e.Message != "" {
+		return e.Message
+	}
+	return http.StatusText(e.Status)
+}
+
+//Unwrap func returns the underlying error so errors.Is and errors.As can look through a HTTPError
+func (e *HTTPError) Unwrap() error {
+	return e.Err
+}
+
+//StatusCode func returns the status code of the error
+func (e *HTTPError) StatusCode() int {
+	return e.Status
+}
+
+//NewHTTPError func creates a HTTPError with the status code and message
+func NewHTTPError(status int, message string) *HTTPError {
+	return &HTTPError{
+		Status:  status,
+		Message: message,
+	}
+}
+
+//BadRequest func creates a 400 HTTPError
+func BadRequest(message string) error {
+	return NewHTTPError(http.StatusBadRequest, message)
+}
+
+//Unauthorized func creates a 401 HTTPError
+func Unauthorized(message string) error {
+	return NewHTTPError(http.StatusUnauthorized, message)
+}
+
+//Forbidden func creates a 403 HTTPError
+func Forbidden(message string) error {
+	return NewHTTPError(http.StatusForbidden, message)
+}
+
+//NotFound func creates a 404 HTTPError
+func NotFound(message string) error {
+	return NewHTTPError(http.StatusNotFound, message)
+}
+
+//ValidationError is returned when a request does not pass validation, it is answered with 400
+type ValidationError struct {
+	Field   string
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	if e.Field == "" {
+		return e.Message
+	}
+	return fmt.Sprintf("%s: %s", e.Field, e.Message)
+}
+
+//ClientMessage func returns the field and the message, both are meant for the client
+func (e *ValidationError) ClientMessage() string {
+	return e.Error()
+}
+
+//StatusCode func returns 400 for every validation error
+func (e *ValidationError) StatusCode() int {
+	return http.StatusBadRequest
+}
+
+//ErrorTranslator func maps an error to the status code and the message sent to the client
+type ErrorTranslator func(err error) (status int, message string)
+
+//ErrorFormatter func writes the translated error to the response
+type ErrorFormatter func(w http.ResponseWriter, r *http.Request, status int, message string)
+
+//DefaultErrorTranslator uses the status code of the first StatusCoder found in the chain of wrapped errors,
+//any other error is answered with 500. The message is only taken from a StatusCoder which is a ClientMessager too,
+//otherwise the status text is sent so internal error details never reach the client
+func DefaultErrorTranslator(err error) (int, string) {
+	var coder StatusCoder
+	if !errors.As(err, &coder) {
+		return http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError)
+	}
+	if messager, ok := coder.(ClientMessager); ok {
+		return coder.StatusCode(), messager.ClientMessage()
+	}
+	return coder.StatusCode(), http.StatusText(coder.StatusCode())
+}
+
+//DefaultErrorFormatter writes the message as plain text
+func DefaultErrorFormatter(w http.ResponseWriter, r *http.Request, status int, message string) {
+	http.Error(w, message, status)
+}
+
+var errorHandling = struct {
+	mu        sync.RWMutex
+	translate ErrorTranslator
+	format    ErrorFormatter
+}{
+	translate: DefaultErrorTranslator,
+	format:    DefaultErrorFormatter,
+}
+
+//SetErrorTranslator func replaces the translator used by WriteError, nil restores DefaultErrorTranslator
+func SetErrorTranslator(translator ErrorTranslator) {
+	if translator == nil {
+		translator = DefaultErrorTranslator
+	}
+	errorHandling.mu.Lock()
+	defer errorHandling.mu.Unlock()
+	errorHandling.translate = translator
+}
+
+//SetErrorFormatter func replaces the formatter used by WriteError, nil restores DefaultErrorFormatter
+func SetErrorFormatter(formatter ErrorFormatter) {
+	if formatter == nil {
+		formatter = DefaultErrorFormatter
+	}
+	errorHandling.mu.Lock()
+	defer errorHandling.mu.Unlock()
+	errorHandling.format = formatter
+}
+
+//WriteError func translates the error and writes the error response.
+//Errors returned by a HandlerE and panics caught by Recovery both go through it so clients see the same format.
+//Nothing is written if w is a ResponseWriter whose response has already been started, HandlerE always passes one
+func WriteError(w http.ResponseWriter, r *http.Request, err error) {
+	if rw, ok := w.(ResponseWriter); ok && rw.Written() {
+		return
+	}
+	errorHandling.mu.RLock()
+	translate, format := errorHandling.translate, errorHandling.format
+	errorHandling.mu.RUnlock()
+
+	status, message := translate(err)
+	format(w, r, status, message)
+}
@@ -0,0 +1,101 @@
+package goat
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+//statusOnlyError knows its status code but its message is not meant for the client
+type statusOnlyError struct{}
+
+func (statusOnlyError) Error() string   { return "internal detail" }
+func (statusOnlyError) StatusCode() int { return http.StatusTeapot }
+
+func Test_HTTPError_Error(t *testing.T) {
+	err := &HTTPError{Status: http.StatusBadGateway, Err: errors.New("dial tcp 10.0.0.7:5432")}
+	assert.Equal(t, "Bad Gateway: dial tcp 10.0.0.7:5432", err.Error(), "Underlying error missing from Error")
+	assert.Equal(t, "Bad Gateway", err.ClientMessage(), "Client message does not match")
+}
+
+func Test_ThenE_TypedErrors(t *testing.T) {
+	tests := []struct {
+		err    error
+		status int
+		body   string
+	}{
+		{NotFound("user 42 not found"), http.StatusNotFound, "user 42 not found\n"},
+		{Unauthorized(""), http.StatusUnauthorized, "Unauthorized\n"},
+		{&ValidationError{Field: "email", Message: "is required"}, http.StatusBadRequest, "email: is required\n"},
+		{fmt.Errorf("loading order: %w", Forbidden("not your order")), http.StatusForbidden, "not your order\n"},
+		{errors.New("database is down"), http.StatusInternalServerError, "Internal Server Error\n"},
+		{&HTTPError{Status: http.StatusBadGateway, Err: errors.New("dial tcp 10.0.0.7:5432")}, http.StatusBadGateway, "Bad Gateway\n"},
+		{&HTTPError{Status: http.StatusConflict, Message: "already exists", Err: errors.New("duplicate key")}, http.StatusConflict, "already exists\n"},
+		{statusOnlyError{}, http.StatusTeapot, "I'm a teapot\n"},
+	}
+	for _, tt := range tests {
+		err := tt.err
+		h := New(NoCache).ThenE(func(w http.ResponseWriter, r *http.Request) error {
+			return err
+		})
+		rr := serve(h, "GET", "/", nil, nil)
+		assert.Equal(t, tt.status, rr.Code, "Status Code does not match for %v", err)
+		assert.Equal(t, tt.body, rr.Body.String(), "Body does not match for %v", err)
+	}
+}
+
+func Test_ThenE_NoError(t *testing.T) {
+	h := New().ThenE(func(w http.ResponseWriter, r *http.Request) error {
+		fmt.Fprint(w, "ok")
+		return nil
+	})
+	rr := serve(h, "GET", "/", nil, nil)
+	assert.Equal(t, http.StatusOK, rr.Code, "Status Code does not match")
+	assert.Equal(t, "ok", rr.Body.String(), "Body does not match")
+}
+
+func Test_ThenE_AlreadyWritten(t *testing.T) {
+	h := New(NoCache).ThenE(func(w http.ResponseWriter, r *http.Request) error {
+		fmt.Fprint(w, "partial")
+		return errors.New("too late")
+	})
+	rr := serve(h, "GET", "/", nil, nil)
+	assert.Equal(t, http.StatusOK, rr.Code, "Status Code was overwritten")
+	assert.Equal(t, "partial", rr.Body.String(), "Error written after the body started without a wrapping middleware")
+
+	h = New(Logger).ThenE(func(w http.ResponseWriter, r *http.Request) error {
+		w.WriteHeader(http.StatusAccepted)
+		return errors.New("too late")
+	})
+	rr = serve(h, "GET", "/", nil, nil)
+	assert.Equal(t, http.StatusAccepted, rr.Code, "Status Code was overwritten")
+	assert.Equal(t, "", rr.Body.String(), "Error written after the response started")
+}
+
+func Test_ErrorTranslator_SharedWithRecovery(t *testing.T) {
+	SetErrorTranslator(func(err error) (int, string) {
+		status, _ := DefaultErrorTranslator(err)
+		return status, "something went wrong"
+	})
+	SetErrorFormatter(func(w http.ResponseWriter, r *http.Request, status int, message string) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		fmt.Fprintf(w, `{"error":%q}`, message)
+	})
+	defer SetErrorTranslator(nil)
+	defer SetErrorFormatter(nil)
+
+	returned := serve(New(Recovery).ThenE(func(w http.ResponseWriter, r *http.Request) error {
+		return errors.New("boom")
+	}), "GET", "/", nil, nil)
+	panicked := serve(New(Recovery).Then(&TestPanicHandler{}), "GET", "/", nil, nil)
+
+	assert.Equal(t, http.StatusInternalServerError, returned.Code, "Status Code does not match")
+	assert.Equal(t, returned.Code, panicked.Code, "Panic and error status differ")
+	assert.Equal(t, `{"error":"something went wrong"}`, returned.Body.String(), "Body does not match")
+	assert.Equal(t, returned.Body.String(), panicked.Body.String(), "Panic and error body differ")
+	assert.Equal(t, "application/json", panicked.Header().Get("Content-Type"), "Formatter not used by Recovery")
+}
@@ -0,0 +1,82 @@
+//go:build ignore
+
+//genResponseWriterWrappers generates responseWriterWrappers.go, run it with go generate.
+//Every goat writer gets one wrap func with a case for each combination of optional interfaces,
+//so adding a writer means adding it to the writers list below instead of copying the switch
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"strings"
+)
+
+//writer describes a goat writer which needs a wrap func
+type writer struct {
+	Func   string //name of the generated func
+	Doc    string //doc comment of the generated func
+	Base   string //interface implemented by the writer, it is embedded in every returned struct
+	Result string //type returned by the generated func
+}
+
+var writers = []writer{
+	{
+		Func: "wrapResponseWriter",
+		Doc: "//wrapResponseWriter returns rw with exactly the optional interfaces the writer it wraps supports,\n" +
+			"//so type assertions on it give the same answers as on the original writer",
+		Base:   "baseWriter",
+		Result: "ResponseWriter",
+	},
+	{
+		Func:   "wrapBufferedResponseWriter",
+		Doc:    "//wrapBufferedResponseWriter is wrapResponseWriter for the buffered writer",
+		Base:   "bufferedBaseWriter",
+		Result: "BufferedResponseWriter",
+	},
+}
+
+//optional interfaces in the order of their flags, each one is implemented by a type calling the base writer
+var optionals = []struct {
+	flag, iface, impl string
+}{
+	{"flusherFlag", "http.Flusher", "responseFlusher"},
+	{"hijackerFlag", "http.Hijacker", "responseHijacker"},
+	{"closeNotifierFlag", "http.CloseNotifier", "responseCloseNotifier"},
+	{"pusherFlag", "http.Pusher", "responsePusher"},
+	{"readerFromFlag", "io.ReaderFrom", "responseReaderFrom"},
+}
+
+func main() {
+	var buf bytes.Buffer
+	buf.WriteString("// Code generated by genResponseWriterWrappers.go; DO NOT EDIT.\n\n")
+	buf.WriteString("package goat\n\nimport (\n\"io\"\n\"net/http\"\n)\n")
+	for _, w := range writers {
+		fmt.Fprintf(&buf, "\n%s\nfunc %s(rw %s) %s {\n", w.Doc, w.Func, w.Base, w.Result)
+		buf.WriteString("switch interfaceFlags(rw.Unwrap()) {\n")
+		for set := 1; set < 1<<len(optionals); set++ {
+			var flags, fields, values []string
+			values = append(values, "rw")
+			for i, o := range optionals {
+				if set&(1<<i) == 0 {
+					continue
+				}
+				flags = append(flags, o.flag)
+				fields = append(fields, o.iface)
+				values = append(values, o.impl+"{rw}")
+			}
+			fmt.Fprintf(&buf, "case %s:\n", strings.Join(flags, " | "))
+			fmt.Fprintf(&buf, "return struct {\n%s\n%s\n}{%s}\n", w.Base, strings.Join(fields, "\n"), strings.Join(values, ", "))
+		}
+		buf.WriteString("}\nreturn rw\n}\n")
+	}
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := ioutil.WriteFile("responseWriterWrappers.go", src, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
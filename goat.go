@@ -7,14 +7,36 @@ import (
 //Middleware type which accepts a http.Handler and returns a http.Handler
 type Middleware func(http.Handler) http.Handler
 
+//NamedMiddleware pairs a Middleware with the name it is listed under in a MiddlewareChain
+type NamedMiddleware struct {
+	Name       string
+	Middleware Middleware
+	Lifecycle  Lifecycle //optional, set for stateful middlewares so the chain can start and close them
+}
+
 //MiddlewareChain struct contains array of all the middlewares that are in the chain
 type MiddlewareChain struct {
-	middlewares []Middleware
+	middlewares []NamedMiddleware
+}
+
+//Named func gives a middleware an explicit name, useful for closures which have no readable name of their own
+func Named(name string, middleware Middleware) NamedMiddleware {
+	return NamedMiddleware{
+		Name:       name,
+		Middleware: middleware,
+	}
 }
 
 //New func accepts any number of middleware type func which are used to create a new middleware chain
 func New(middlewares ...Middleware) MiddlewareChain {
-	var m []Middleware
+	return MiddlewareChain{
+		middlewares: nameAll(middlewares),
+	}
+}
+
+//NewNamed func is similar to New but accepts middlewares that already carry a name
+func NewNamed(middlewares ...NamedMiddleware) MiddlewareChain {
+	var m []NamedMiddleware
 	m = append(m, middlewares...)
 	return MiddlewareChain{
 		middlewares: m,
@@ -27,7 +49,7 @@ func (mc MiddlewareChain) Then(handler http.Handler) http.Handler {
 		handler = http.DefaultServeMux
 	}
 	for i := range mc.middlewares {
-		handler = mc.middlewares[len(mc.middlewares)-i-1](handler)
+		handler = mc.middlewares[len(mc.middlewares)-i-1].Middleware(handler)
 	}
 
 	return handler
@@ -40,7 +62,12 @@ func (mc MiddlewareChain) ThenFunc(handlerFunc http.HandlerFunc) http.Handler {
 
 //Append func creates a new middleware without touching the original middleware chain
 func (mc MiddlewareChain) Append(middlewares ...Middleware) MiddlewareChain {
-	var newMiddlewares []Middleware
+	return mc.AppendNamed(nameAll(middlewares)...)
+}
+
+//AppendNamed func is similar to Append but accepts middlewares that already carry a name
+func (mc MiddlewareChain) AppendNamed(middlewares ...NamedMiddleware) MiddlewareChain {
+	var newMiddlewares []NamedMiddleware
 	newMiddlewares = append(newMiddlewares, mc.middlewares...)
 	newMiddlewares = append(newMiddlewares, middlewares...)
 	return MiddlewareChain{
@@ -50,10 +77,34 @@ func (mc MiddlewareChain) Append(middlewares ...Middleware) MiddlewareChain {
 
 //AppendToChain func append a middleware to the current middleware chain
 func (mc MiddlewareChain) AppendToChain(middlewares ...Middleware) MiddlewareChain {
-	mc.middlewares = append(mc.middlewares, middlewares...)
+	//limit the capacity so append always copies and never writes into the backing array of the original chain
+	current := mc.middlewares[:len(mc.middlewares):len(mc.middlewares)]
+	mc.middlewares = append(current, nameAll(middlewares)...)
 	return mc
 }
 
+//Len func returns the number of middlewares in the chain
+func (mc MiddlewareChain) Len() int {
+	return len(mc.middlewares)
+}
+
+//List func returns the middlewares of the chain in the order they wrap the handler, outermost first.
+//The returned slice is a copy so changing it does not change the chain
+func (mc MiddlewareChain) List() []NamedMiddleware {
+	list := make([]NamedMiddleware, len(mc.middlewares))
+	copy(list, mc.middlewares)
+	return list
+}
+
+//Names func returns the names of the middlewares in the chain, outermost first
+func (mc MiddlewareChain) Names() []string {
+	names := make([]string, len(mc.middlewares))
+	for i, m := range mc.middlewares {
+		names[i] = m.Name
+	}
+	return names
+}
+
 //CommonMiddlewares func for crearting a few common middlewares like logger, nocache header and recovery
 func CommonMiddlewares() MiddlewareChain {
 	mc := New(NoCache, Recovery, Logger)
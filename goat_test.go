@@ -1,7 +1,9 @@
 package goat
 
 import (
+	"context"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"net/http/httptest"
@@ -14,6 +16,18 @@ import (
 	"github.com/stretchr/testify/assert"
 )
 
+//serve sends a request with the given body and headers to the handler and returns the recorded response,
+//the tests of every middleware share it
+func serve(h http.Handler, method string, target string, body io.Reader, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, body)
+	for k, v := range header {
+		req.Header[http.CanonicalHeaderKey(k)] = v
+	}
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	return rr
+}
+
 type TestHandler struct{}
 
 func (h *TestHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
@@ -82,6 +96,7 @@ func Test_Middleware_Compression(t *testing.T) {
 func Test_Middleware_Monitor(t *testing.T) {
 	h := &TestMonitorHandler{}
 	m := NewMonitor()
+	defer m.Close(context.Background())
 	commonMiddlewares := CommonMiddlewares()
 	monitAddedMiddleware := commonMiddlewares.Append(m.Monitor)
 	server := httptest.NewServer(monitAddedMiddleware.Then(h))
@@ -0,0 +1,113 @@
+package goattest
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+//ParseCSP func splits a Content-Security-Policy header into its directives and their sources
+func ParseCSP(policy string) map[string][]string {
+	directives := map[string][]string{}
+	for _, part := range strings.Split(policy, ";") {
+		fields := strings.Fields(part)
+		if len(fields) == 0 {
+			continue
+		}
+		name := strings.ToLower(fields[0])
+		if _, ok := directives[name]; ok {
+			//browsers ignore repeated directives, so do we
+			continue
+		}
+		directives[name] = fields[1:]
+	}
+	return directives
+}
+
+//AssertHeader func fails the test if the header does not have the value
+func AssertHeader(t testing.TB, header http.Header, name string, want string) bool {
+	t.Helper()
+	if got := header.Get(name); got != want {
+		t.Errorf("header %s = %q, want %q", name, got, want)
+		return false
+	}
+	return true
+}
+
+//AssertNoHeader func fails the test if the header is set
+func AssertNoHeader(t testing.TB, header http.Header, name string) bool {
+	t.Helper()
+	if values, ok := header[http.CanonicalHeaderKey(name)]; ok {
+		t.Errorf("header %s = %q, want it unset", name, values)
+		return false
+	}
+	return true
+}
+
+//AssertNoCache func fails the test unless the headers set by goat.NoCache are present
+func AssertNoCache(t testing.TB, header http.Header) bool {
+	t.Helper()
+	ok := true
+	cacheControl := strings.ToLower(header.Get("Cache-Control"))
+	for _, directive := range []string{"no-cache", "no-store", "must-revalidate"} {
+		if !strings.Contains(cacheControl, directive) {
+			t.Errorf("Cache-Control = %q, want it to contain %s", header.Get("Cache-Control"), directive)
+			ok = false
+		}
+	}
+	ok = AssertHeader(t, header, "Pragma", "no-cache") && ok
+	ok = AssertHeader(t, header, "Expires", "0") && ok
+	return ok
+}
+
+//AssertXSSProtection func fails the test unless the header set by goat.XSS is present
+func AssertXSSProtection(t testing.TB, header http.Header) bool {
+	t.Helper()
+	return AssertHeader(t, header, "X-XSS-Protection", "1; mode=block")
+}
+
+//AssertCSP func fails the test unless the enforced Content-Security-Policy has the directive with at least the given sources
+func AssertCSP(t testing.TB, header http.Header, directive string, sources ...string) bool {
+	t.Helper()
+	return assertPolicy(t, header, "Content-Security-Policy", directive, sources)
+}
+
+//AssertCSPReportOnly func is AssertCSP for the Content-Security-Policy-Report-Only header
+func AssertCSPReportOnly(t testing.TB, header http.Header, directive string, sources ...string) bool {
+	t.Helper()
+	return assertPolicy(t, header, "Content-Security-Policy-Report-Only", directive, sources)
+}
+
+func assertPolicy(t testing.TB, header http.Header, name string, directive string, sources []string) bool {
+	t.Helper()
+	policies := header.Values(name)
+	if len(policies) == 0 {
+		t.Errorf("header %s is not set", name)
+		return false
+	}
+	//with several policies a directive only has to be found in one of them
+	for _, policy := range policies {
+		got, ok := ParseCSP(policy)[strings.ToLower(directive)]
+		if ok && containsAll(got, sources) {
+			return true
+		}
+	}
+	t.Errorf("header %s = %q, want directive %s with sources %q", name, policies, directive, sources)
+	return false
+}
+
+func containsAll(values []string, wanted []string) bool {
+	for _, w := range wanted {
+		found := false
+		for _, v := range values {
+			if v == w {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
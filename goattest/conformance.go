@@ -0,0 +1,99 @@
+package goattest
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/com-redbus/goat"
+)
+
+type conformanceKey struct{}
+
+//RunConformance func runs a set of subtests every well behaved middleware should pass.
+//The middleware is checked to
+//  - call the next handler exactly once
+//  - keep http.Flusher and http.Hijacker when the writer it gets supports them
+//  - pass the status code and body of the next handler through for plain requests
+//  - pass the request context on to the next handler
+//
+//Middlewares that answer some requests themselves still have to call next for a plain GET of /
+func RunConformance(t *testing.T, middleware goat.Middleware) {
+	t.Run("CallsNextOnce", func(t *testing.T) {
+		calls := 0
+		h := middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls++
+		}))
+		Get("/").Serve(h)
+		if calls != 1 {
+			t.Errorf("next handler called %d times, want 1", calls)
+		}
+	})
+
+	t.Run("KeepsFlusher", func(t *testing.T) {
+		flushable := false
+		h := middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var flusher http.Flusher
+			flusher, flushable = w.(http.Flusher)
+			if flushable {
+				fmt.Fprint(w, "chunk")
+				flusher.Flush()
+			}
+		}))
+		rec := Get("/").Serve(h)
+		if !flushable {
+			t.Errorf("next handler got a %T which is no http.Flusher", rec)
+			return
+		}
+		if rec.Flushes == 0 {
+			t.Errorf("Flush did not reach the underlying writer")
+		}
+	})
+
+	t.Run("KeepsHijacker", func(t *testing.T) {
+		hijackable := false
+		h := middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			hijacker, ok := w.(http.Hijacker)
+			if !ok {
+				return
+			}
+			conn, _, err := hijacker.Hijack()
+			if err != nil {
+				return
+			}
+			hijackable = true
+			conn.Close()
+		}))
+		rec := NewHijackRecorder()
+		h.ServeHTTP(rec, Get("/").Build())
+		if !hijackable || !rec.Hijacked {
+			t.Errorf("next handler could not hijack the connection")
+		}
+		if rec.Peer != nil {
+			rec.Peer.Close()
+		}
+	})
+
+	t.Run("PassesStatusAndBody", func(t *testing.T) {
+		rec := Get("/").Serve(middleware(TextHandler(http.StatusTeapot, "short and stout")))
+		if rec.Status() != http.StatusTeapot {
+			t.Errorf("status = %d, want %d", rec.Status(), http.StatusTeapot)
+		}
+		if rec.BodyString() != "short and stout" {
+			t.Errorf("body = %q, want %q", rec.BodyString(), "short and stout")
+		}
+	})
+
+	t.Run("PassesContext", func(t *testing.T) {
+		var got interface{}
+		h := middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			got = r.Context().Value(conformanceKey{})
+		}))
+		ctx := context.WithValue(context.Background(), conformanceKey{}, "kept")
+		Get("/").Context(ctx).Serve(h)
+		if got != "kept" {
+			t.Errorf("request context value = %v, want kept", got)
+		}
+	})
+}
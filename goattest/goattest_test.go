@@ -0,0 +1,101 @@
+package goattest
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/com-redbus/goat"
+)
+
+//recordingT records failures instead of failing the test
+type recordingT struct {
+	testing.TB
+	failed bool
+}
+
+func (r *recordingT) Helper() {}
+
+func (r *recordingT) Errorf(format string, args ...interface{}) {
+	r.failed = true
+}
+
+func TestRunConformance_BuiltIns(t *testing.T) {
+	csp, err := goat.NewCSP(goat.CSPOptions{DefaultSrc: []string{"'self'"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	middlewares := map[string]goat.Middleware{
+		"NoCache":     goat.NoCache,
+		"XSS":         goat.XSS,
+		"Logger":      goat.Logger,
+		"Recovery":    goat.Recovery,
+		"CSP":         csp.CSP,
+		"Compression": goat.Compression,
+	}
+	for name, m := range middlewares {
+		t.Run(name, func(t *testing.T) {
+			RunConformance(t, m)
+		})
+	}
+}
+
+func TestRequestBuilder(t *testing.T) {
+	req := Post("/users").
+		Query("page", "2").
+		Header("X-Request-ID", "abc").
+		JSON(map[string]string{"name": "goat"}).
+		Build()
+
+	if req.Method != http.MethodPost || req.URL.Path != "/users" || req.URL.Query().Get("page") != "2" {
+		t.Errorf("request = %s %s, want POST /users?page=2", req.Method, req.URL)
+	}
+	AssertHeader(t, req.Header, "Content-Type", "application/json")
+	AssertHeader(t, req.Header, "X-Request-ID", "abc")
+	if req.ContentLength != int64(len(`{"name":"goat"}`)) {
+		t.Errorf("content length = %d", req.ContentLength)
+	}
+}
+
+func TestHeaderAssertions(t *testing.T) {
+	csp, err := goat.NewCSP(goat.CSPOptions{
+		DefaultSrc: []string{"'self'", "cdn.example.com"},
+		ScriptSrc:  []string{"'self'"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := goat.New(goat.NoCache, goat.XSS, csp.CSP).Then(TextHandler(http.StatusOK, "ok"))
+	rec := Get("/").Serve(h)
+
+	AssertNoCache(t, rec.Headers())
+	AssertXSSProtection(t, rec.Headers())
+	AssertCSP(t, rec.Headers(), "default-src", "cdn.example.com")
+	AssertCSP(t, rec.Headers(), "script-src", "'self'")
+	AssertNoHeader(t, rec.Headers(), "Content-Security-Policy-Report-Only")
+
+	failing := &recordingT{TB: t}
+	if AssertCSP(failing, rec.Headers(), "img-src", "'self'") || !failing.failed {
+		t.Errorf("missing directive passed the assertion")
+	}
+}
+
+func TestHandlers(t *testing.T) {
+	rec := Get("/").Serve(goat.Recovery(PanicHandler("boom")))
+	if rec.Status() != http.StatusInternalServerError {
+		t.Errorf("status = %d, want 500", rec.Status())
+	}
+
+	rec = Get("/").Serve(StreamingHandler(0, "a", "b", "c"))
+	if rec.BodyString() != "abc" || rec.Flushes != 3 {
+		t.Errorf("body = %q flushes = %d, want abc and 3", rec.BodyString(), rec.Flushes)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	rec = Get("/").Context(ctx).Serve(SlowHandler(time.Hour, "too late"))
+	if rec.BodyString() != "" {
+		t.Errorf("slow handler wrote %q after the request was canceled", rec.BodyString())
+	}
+}
@@ -0,0 +1,56 @@
+package goattest
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+//TextHandler func returns a handler which answers every request with the status and body
+func TextHandler(status int, body string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(status)
+		fmt.Fprint(w, body)
+	})
+}
+
+//PanicHandler func returns a handler which panics with the value
+func PanicHandler(value interface{}) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic(value)
+	})
+}
+
+//SlowHandler func returns a handler which waits for the delay before it writes the body.
+//It gives up without writing anything when the request context is done first
+func SlowHandler(delay time.Duration, body string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+			fmt.Fprint(w, body)
+		case <-r.Context().Done():
+		}
+	})
+}
+
+//StreamingHandler func returns a handler which writes the chunks one by one and flushes after every chunk,
+//waiting interval between them. It answers 500 if the writer it gets is no http.Flusher
+func StreamingHandler(interval time.Duration, chunks ...string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			http.Error(w, "streaming not supported", http.StatusInternalServerError)
+			return
+		}
+		for i, chunk := range chunks {
+			if i > 0 && interval > 0 {
+				time.Sleep(interval)
+			}
+			fmt.Fprint(w, chunk)
+			flusher.Flush()
+		}
+	})
+}
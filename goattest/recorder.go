@@ -0,0 +1,77 @@
+package goattest
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"net/http/httptest"
+)
+
+//Recorder records what a handler writes: status, headers, body, trailers and how often it flushed.
+//It implements http.Flusher like the writers of net/http do
+type Recorder struct {
+	*httptest.ResponseRecorder
+	Flushes          int //number of Flush calls
+	WriteHeaderCalls int //number of WriteHeader calls that reached the recorder
+}
+
+//NewRecorder func creates an empty Recorder
+func NewRecorder() *Recorder {
+	return &Recorder{
+		ResponseRecorder: httptest.NewRecorder(),
+	}
+}
+
+//WriteHeader func records the status code
+func (r *Recorder) WriteHeader(code int) {
+	r.WriteHeaderCalls++
+	r.ResponseRecorder.WriteHeader(code)
+}
+
+//Flush func records the flush
+func (r *Recorder) Flush() {
+	r.Flushes++
+	r.ResponseRecorder.Flush()
+}
+
+//Status func returns the status code sent, 200 if the handler did not write anything
+func (r *Recorder) Status() int {
+	return r.Result().StatusCode
+}
+
+//Headers func returns the headers as they were when the response was written
+func (r *Recorder) Headers() http.Header {
+	return r.Result().Header
+}
+
+//BodyString func returns the body written so far
+func (r *Recorder) BodyString() string {
+	return r.Body.String()
+}
+
+//Trailers func returns the trailers set by the handler
+func (r *Recorder) Trailers() http.Header {
+	return r.Result().Trailer
+}
+
+//HijackRecorder is a Recorder which also implements http.Hijacker, the connection is one end of a net.Pipe
+type HijackRecorder struct {
+	*Recorder
+	Hijacked bool
+	Peer     net.Conn //the other end of the hijacked connection, nil until Hijack is called
+}
+
+//NewHijackRecorder func creates an empty HijackRecorder
+func NewHijackRecorder() *HijackRecorder {
+	return &HijackRecorder{
+		Recorder: NewRecorder(),
+	}
+}
+
+//Hijack func hands out one end of a net.Pipe
+func (r *HijackRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, peer := net.Pipe()
+	r.Hijacked = true
+	r.Peer = peer
+	return conn, bufio.NewReadWriter(bufio.NewReader(conn), bufio.NewWriter(conn)), nil
+}
@@ -0,0 +1,130 @@
+//Package goattest has helpers for testing goat middlewares and the handlers behind them
+package goattest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+)
+
+//RequestBuilder builds a *http.Request step by step, e.g.
+//
+//	rec := goattest.Get("/api/users").Header("Accept-Encoding", "gzip").Serve(handler)
+type RequestBuilder struct {
+	method string
+	target string
+	header http.Header
+	query  url.Values
+	body   io.Reader
+	ctx    context.Context
+	err    error
+}
+
+//NewRequest func starts building a request with the method and target, the target may be a path or a full url
+func NewRequest(method string, target string) *RequestBuilder {
+	return &RequestBuilder{
+		method: method,
+		target: target,
+		header: http.Header{},
+		query:  url.Values{},
+	}
+}
+
+//Get func starts building a GET request
+func Get(target string) *RequestBuilder {
+	return NewRequest(http.MethodGet, target)
+}
+
+//Head func starts building a HEAD request
+func Head(target string) *RequestBuilder {
+	return NewRequest(http.MethodHead, target)
+}
+
+//Post func starts building a POST request
+func Post(target string) *RequestBuilder {
+	return NewRequest(http.MethodPost, target)
+}
+
+//Header func adds a request header
+func (b *RequestBuilder) Header(name string, value string) *RequestBuilder {
+	b.header.Add(name, value)
+	return b
+}
+
+//AcceptEncoding func sets the Accept-Encoding header
+func (b *RequestBuilder) AcceptEncoding(encodings string) *RequestBuilder {
+	b.header.Set("Accept-Encoding", encodings)
+	return b
+}
+
+//Query func adds a query parameter to the target
+func (b *RequestBuilder) Query(name string, value string) *RequestBuilder {
+	b.query.Add(name, value)
+	return b
+}
+
+//Body func sets the request body
+func (b *RequestBuilder) Body(body string) *RequestBuilder {
+	b.body = strings.NewReader(body)
+	return b
+}
+
+//BodyBytes func sets the request body
+func (b *RequestBuilder) BodyBytes(body []byte) *RequestBuilder {
+	b.body = bytes.NewReader(body)
+	return b
+}
+
+//JSON func sets the body to the json encoding of v and the Content-Type to application/json
+func (b *RequestBuilder) JSON(v interface{}) *RequestBuilder {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		b.err = err
+		return b
+	}
+	b.header.Set("Content-Type", "application/json")
+	return b.BodyBytes(payload)
+}
+
+//Context func sets the request context
+func (b *RequestBuilder) Context(ctx context.Context) *RequestBuilder {
+	b.ctx = ctx
+	return b
+}
+
+//Build func creates the request, it panics if the body could not be encoded
+func (b *RequestBuilder) Build() *http.Request {
+	if b.err != nil {
+		panic(b.err)
+	}
+	req := httptest.NewRequest(b.method, b.target, b.body)
+	if len(b.query) != 0 {
+		q := req.URL.Query()
+		for k, values := range b.query {
+			for _, v := range values {
+				q.Add(k, v)
+			}
+		}
+		req.URL.RawQuery = q.Encode()
+		req.RequestURI = req.URL.RequestURI()
+	}
+	for k, values := range b.header {
+		req.Header[k] = append([]string(nil), values...)
+	}
+	if b.ctx != nil {
+		req = req.WithContext(b.ctx)
+	}
+	return req
+}
+
+//Serve func builds the request, serves it with the handler and returns the recorded response
+func (b *RequestBuilder) Serve(handler http.Handler) *Recorder {
+	rec := NewRecorder()
+	handler.ServeHTTP(rec, b.Build())
+	return rec
+}
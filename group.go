@@ -0,0 +1,125 @@
+package goat
+
+import (
+	"net/http"
+	"strings"
+)
+
+//Router is the part of http.ServeMux a Group registers its routes on, any router with the same Handle method works
+type Router interface {
+	Handle(pattern string, handler http.Handler)
+}
+
+//Group struct registers routes below a path prefix, every route is wrapped in the middleware chain of the group
+type Group struct {
+	router Router
+	prefix string
+	chain  MiddlewareChain
+}
+
+//NewGroup func creates a group for the prefix on the router, e.g. goat.NewGroup(mux, "/api", goat.CommonMiddlewares())
+func NewGroup(router Router, prefix string, chain MiddlewareChain) *Group {
+	return &Group{
+		router: router,
+		prefix: cleanPrefix(prefix),
+		chain:  chain,
+	}
+}
+
+//cleanPrefix makes sure a prefix starts with a slash and does not end with one, the root prefix is empty
+func cleanPrefix(prefix string) string {
+	prefix = strings.TrimSuffix(prefix, "/")
+	if prefix != "" && !strings.HasPrefix(prefix, "/") {
+		prefix = "/" + prefix
+	}
+	return prefix
+}
+
+//Group func creates a subgroup below the prefix of g, its chain is the chain of g followed by the middlewares
+func (g *Group) Group(prefix string, middlewares ...Middleware) *Group {
+	return g.GroupChain(prefix, New(middlewares...))
+}
+
+//GroupChain func is similar to Group but extends the chain of g with a whole chain
+func (g *Group) GroupChain(prefix string, chain MiddlewareChain) *Group {
+	return &Group{
+		router: g.router,
+		prefix: g.prefix + cleanPrefix(prefix),
+		chain:  g.chain.Merge(chain),
+	}
+}
+
+//Use func appends middlewares to the chain of the group, only routes registered afterwards get them
+func (g *Group) Use(middlewares ...Middleware) {
+	g.chain = g.chain.Append(middlewares...)
+}
+
+//Prefix func returns the path prefix of the group
+func (g *Group) Prefix() string {
+	return g.prefix
+}
+
+//Chain func returns the middleware chain of the group
+func (g *Group) Chain() MiddlewareChain {
+	return g.chain
+}
+
+//Pattern func returns the pattern a route of the group is registered under.
+//Method and host of Go 1.22 patterns are kept, e.g. "GET /users/{id}" in the /api group becomes "GET /api/users/{id}"
+func (g *Group) Pattern(pattern string) string {
+	method, host, path := splitPattern(pattern)
+	if g.prefix != "" {
+		if path == "" {
+			path = g.prefix
+		} else {
+			path = g.prefix + path
+		}
+	}
+	if method != "" {
+		return method + " " + host + path
+	}
+	return host + path
+}
+
+//splitPattern splits a ServeMux pattern into [METHOD ][HOST]/[PATH]
+func splitPattern(pattern string) (method string, host string, path string) {
+	pattern = strings.TrimSpace(pattern)
+	if i := strings.IndexAny(pattern, " \t"); i >= 0 && !strings.Contains(pattern[:i], "/") {
+		method = pattern[:i]
+		pattern = strings.TrimLeft(pattern[i:], " \t")
+	}
+	if i := strings.Index(pattern, "/"); i >= 0 {
+		return method, pattern[:i], pattern[i:]
+	}
+	return method, pattern, ""
+}
+
+//Handle func registers the handler wrapped in the chain of the group
+func (g *Group) Handle(pattern string, handler http.Handler) {
+	pattern = g.Pattern(pattern)
+	g.router.Handle(pattern, withRoutePattern(pattern, g.chain.Then(handler)))
+}
+
+//HandleFunc func registers the handler func wrapped in the chain of the group
+func (g *Group) HandleFunc(pattern string, handler http.HandlerFunc) {
+	g.Handle(pattern, handler)
+}
+
+//HandleE func registers the error returning handler wrapped in the chain of the group
+func (g *Group) HandleE(pattern string, handler HandlerE) {
+	g.Handle(pattern, handler)
+}
+
+//Mount func registers the handler for the whole subtree below path, the group prefix and path are stripped
+//from the request before the handler sees it. This suits handlers which route on their own, like a file server
+func (g *Group) Mount(path string, handler http.Handler) {
+	root := g.prefix + cleanPrefix(path)
+	g.router.Handle(root+"/", withRoutePattern(root+"/", g.chain.Then(http.StripPrefix(root, handler))))
+}
+
+//withRoutePattern stores the pattern before the chain runs so every middleware of the group can read it
+func withRoutePattern(pattern string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, SetRoutePattern(r, pattern))
+	})
+}
@@ -0,0 +1,64 @@
+package goat
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Group_Pattern(t *testing.T) {
+	g := NewGroup(http.NewServeMux(), "/api/", New())
+	assert.Equal(t, "/api", g.Prefix(), "Prefix not cleaned")
+	assert.Equal(t, "/api/users", g.Pattern("/users"), "Path pattern does not match")
+	assert.Equal(t, "/api/", g.Pattern("/"), "Subtree pattern does not match")
+	assert.Equal(t, "GET /api/users/{id}", g.Pattern("GET /users/{id}"), "Method pattern does not match")
+	assert.Equal(t, "example.com/api/users", g.Pattern("example.com/users"), "Host pattern does not match")
+	assert.Equal(t, "POST example.com/api/", g.Pattern("POST example.com/"), "Method and host pattern does not match")
+
+	v2 := g.Group("v2")
+	assert.Equal(t, "/api/v2/orders", v2.Pattern("/orders"), "Nested pattern does not match")
+	assert.Equal(t, "/users", NewGroup(http.NewServeMux(), "", New()).Pattern("/users"), "Root pattern does not match")
+}
+
+func Test_Group_Routes(t *testing.T) {
+	mux := http.NewServeMux()
+	api := NewGroup(mux, "/api", New(NoCache))
+	api.HandleFunc("GET /users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "user "+r.PathValue("id"))
+	})
+	admin := api.Group("/admin", XSS)
+	admin.HandleE("/stats", func(w http.ResponseWriter, r *http.Request) error {
+		return Forbidden("admins only")
+	})
+
+	rr := serve(mux, "GET", "/api/users/42", nil, nil)
+	assert.Equal(t, "user 42", rr.Body.String(), "Route not registered with the method pattern")
+	assert.Equal(t, "no-cache", rr.Header().Get("Pragma"), "Group chain not applied")
+	assert.Equal(t, "", rr.Header().Get("X-XSS-Protection"), "Subgroup chain leaked into the parent")
+
+	rr = serve(mux, "POST", "/api/users/42", nil, nil)
+	assert.Equal(t, http.StatusMethodNotAllowed, rr.Code, "Method pattern not kept")
+
+	rr = serve(mux, "GET", "/api/admin/stats", nil, nil)
+	assert.Equal(t, http.StatusForbidden, rr.Code, "HandleE error not translated")
+	assert.Equal(t, "admins only\n", rr.Body.String(), "HandleE body does not match")
+	assert.Equal(t, "no-cache", rr.Header().Get("Pragma"), "Parent chain not inherited")
+	assert.Equal(t, "1; mode=block", rr.Header().Get("X-XSS-Protection"), "Subgroup chain not applied")
+	assert.Equal(t, []string{"NoCache", "XSS"}, admin.Chain().Names(), "Subgroup chain does not match")
+	assert.Equal(t, []string{"NoCache"}, api.Chain().Names(), "Parent chain changed")
+}
+
+func Test_Group_UseAndMount(t *testing.T) {
+	mux := http.NewServeMux()
+	site := NewGroup(mux, "/site", New())
+	site.Use(XSS)
+	site.Mount("/static", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "file "+r.URL.Path)
+	}))
+
+	rr := serve(mux, "GET", "/site/static/css/app.css", nil, nil)
+	assert.Equal(t, "file /css/app.css", rr.Body.String(), "Mounted handler did not get the stripped path")
+	assert.Equal(t, "1; mode=block", rr.Header().Get("X-XSS-Protection"), "Used middleware not applied")
+}
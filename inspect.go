@@ -0,0 +1,52 @@
+package goat
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+//chainEntry is the json form of a single middleware in the chain
+type chainEntry struct {
+	Position int    `json:"position"`
+	Name     string `json:"name"`
+}
+
+//chainDescription is the json document served by DebugHandler
+type chainDescription struct {
+	Length      int          `json:"length"`
+	Pipeline    string       `json:"pipeline"`
+	Middlewares []chainEntry `json:"middlewares"`
+}
+
+//String func prints the chain as a pipeline in the order a request travels through it
+//e.g. NoCache -> Recovery -> Logger -> handler
+func (mc MiddlewareChain) String() string {
+	parts := append(mc.Names(), "handler")
+	return strings.Join(parts, " -> ")
+}
+
+func (mc MiddlewareChain) describe() chainDescription {
+	entries := make([]chainEntry, len(mc.middlewares))
+	for i, m := range mc.middlewares {
+		entries[i] = chainEntry{
+			Position: i,
+			Name:     m.Name,
+		}
+	}
+	return chainDescription{
+		Length:      len(mc.middlewares),
+		Pipeline:    mc.String(),
+		Middlewares: entries,
+	}
+}
+
+//DebugHandler func returns a http.Handler which serves the chain as json, mount it on a debug route to inspect a chain at runtime
+func (mc MiddlewareChain) DebugHandler() http.Handler {
+	description := mc.describe()
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-cache")
+		json.NewEncoder(w).Encode(description)
+	})
+}
@@ -0,0 +1,37 @@
+package goat
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Chain_String(t *testing.T) {
+	mc := CommonMiddlewares().Append(XSS)
+	assert.Equal(t, "NoCache -> Recovery -> Logger -> XSS -> handler", mc.String(), "Pipeline does not match")
+	assert.Equal(t, "handler", New().String(), "Empty pipeline does not match")
+}
+
+func Test_Chain_DebugHandler(t *testing.T) {
+	mc := CommonMiddlewares()
+	server := httptest.NewServer(mc.DebugHandler())
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var description chainDescription
+	err = json.NewDecoder(resp.Body).Decode(&description)
+	assert.NoError(t, err, "Debug handler did not return json")
+	assert.Equal(t, "application/json", resp.Header.Get("Content-Type"), "Content-Type does not match")
+	assert.Equal(t, 3, description.Length, "Length does not match")
+	assert.Equal(t, mc.String(), description.Pipeline, "Pipeline does not match")
+	assert.Equal(t, "Recovery", description.Middlewares[1].Name, "Middleware name does not match")
+	assert.Equal(t, 1, description.Middlewares[1].Position, "Middleware position does not match")
+}
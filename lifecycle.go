@@ -0,0 +1,142 @@
+package goat
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"reflect"
+	"sync"
+)
+
+//Lifecycle is implemented by stateful middlewares which run background goroutines or buffer data.
+//Start has to be safe to call more than once, Close stops the background work, flushes what is buffered
+//and waits for it to finish or for the context to be done
+type Lifecycle interface {
+	Start() error
+	Close(ctx context.Context) error
+}
+
+//funcLifecycles maps the code pointer of package level middlewares to the lifecycle that backs them
+var funcLifecycles = struct {
+	mu         sync.RWMutex
+	lifecycles map[uintptr]Lifecycle
+}{
+	lifecycles: map[uintptr]Lifecycle{},
+}
+
+func init() {
+	registerLifecycle(RecoverAndLogPanic, defaultPanicLogger)
+}
+
+func registerLifecycle(middleware Middleware, lifecycle Lifecycle) {
+	funcLifecycles.mu.Lock()
+	defer funcLifecycles.mu.Unlock()
+	funcLifecycles.lifecycles[funcPointer(middleware)] = lifecycle
+}
+
+func lifecycleFor(middleware Middleware) Lifecycle {
+	if middleware == nil {
+		return nil
+	}
+	funcLifecycles.mu.RLock()
+	lifecycle := funcLifecycles.lifecycles[funcPointer(middleware)]
+	funcLifecycles.mu.RUnlock()
+	if lifecycle != nil {
+		return lifecycle
+	}
+	if info, ok := describeConditional(middleware); ok {
+		return info.lifecycle
+	}
+	return nil
+}
+
+//Managed func names a middleware and attaches the lifecycle that owns its background work,
+//e.g. goat.Managed("Monitor", monit.Monitor, monit)
+func Managed(name string, middleware Middleware, lifecycle Lifecycle) NamedMiddleware {
+	return NamedMiddleware{
+		Name:       name,
+		Middleware: middleware,
+		Lifecycle:  lifecycle,
+	}
+}
+
+//lifecycles returns the distinct lifecycles of the chain, outermost first.
+//A lifecycle shared by several middlewares is only listed once, unless its type can not be compared
+func (mc MiddlewareChain) lifecycles() []Lifecycle {
+	var list []Lifecycle
+	for _, m := range mc.middlewares {
+		if m.Lifecycle == nil {
+			continue
+		}
+		duplicate := false
+		if reflect.TypeOf(m.Lifecycle).Comparable() {
+			for _, l := range list {
+				if reflect.TypeOf(l).Comparable() && l == m.Lifecycle {
+					duplicate = true
+					break
+				}
+			}
+		}
+		if !duplicate {
+			list = append(list, m.Lifecycle)
+		}
+	}
+	return list
+}
+
+//Start func starts the lifecycle of every stateful middleware in the chain, outermost first.
+//If one fails to start the ones already started are closed again
+func (mc MiddlewareChain) Start() error {
+	lifecycles := mc.lifecycles()
+	for i, l := range lifecycles {
+		if err := l.Start(); err != nil {
+			errs := []error{err}
+			for j := i - 1; j >= 0; j-- {
+				if closeErr := lifecycles[j].Close(context.Background()); closeErr != nil {
+					errs = append(errs, closeErr)
+				}
+			}
+			return errors.Join(errs...)
+		}
+	}
+	return nil
+}
+
+//Shutdown func closes the lifecycle of every stateful middleware in the chain, innermost first,
+//and waits until they are done or the context is done. All errors are returned joined together
+func (mc MiddlewareChain) Shutdown(ctx context.Context) error {
+	lifecycles := mc.lifecycles()
+	var errs []error
+	for i := len(lifecycles) - 1; i >= 0; i-- {
+		if err := lifecycles[i].Close(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+//Close func is Shutdown without a deadline
+func (mc MiddlewareChain) Close() error {
+	return mc.Shutdown(context.Background())
+}
+
+//RegisterOnShutdown func makes server.Shutdown close the stateful middlewares of the chain,
+//errors are logged because http.Server has no way to return them
+func (mc MiddlewareChain) RegisterOnShutdown(server *http.Server) {
+	server.RegisterOnShutdown(func() {
+		if err := mc.Close(); err != nil {
+			log.Println("goat: closing middlewares:", err)
+		}
+	})
+}
+
+//waitContext waits for done to be closed or the context to be done, whichever comes first
+func waitContext(ctx context.Context, done <-chan struct{}) error {
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
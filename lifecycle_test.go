@@ -0,0 +1,132 @@
+package goat
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testLifecycle struct {
+	name     string
+	events   *[]string
+	startErr error
+	block    chan struct{}
+}
+
+func (l *testLifecycle) Start() error {
+	*l.events = append(*l.events, "start "+l.name)
+	return l.startErr
+}
+
+func (l *testLifecycle) Close(ctx context.Context) error {
+	*l.events = append(*l.events, "close "+l.name)
+	if l.block != nil {
+		return waitContext(ctx, l.block)
+	}
+	return nil
+}
+
+func Test_Chain_Lifecycle_Order(t *testing.T) {
+	var events []string
+	first := &testLifecycle{name: "first", events: &events}
+	second := &testLifecycle{name: "second", events: &events}
+
+	mc := NewNamed(
+		Managed("First", sampleMiddleware, first),
+		Named("Plain", sampleMiddleware),
+		Managed("Second", sampleMiddleware, second),
+		Managed("SecondAgain", sampleMiddleware, second),
+	).Append(NoCache)
+
+	assert.NoError(t, mc.Start(), "Start failed")
+	assert.NoError(t, mc.Close(), "Close failed")
+	assert.Equal(t, []string{"start first", "start second", "close second", "close first"}, events, "Lifecycle order does not match")
+}
+
+func Test_Chain_Lifecycle_StartFailure(t *testing.T) {
+	var events []string
+	first := &testLifecycle{name: "first", events: &events}
+	broken := &testLifecycle{name: "broken", events: &events, startErr: errors.New("no config")}
+
+	mc := NewNamed(Managed("First", sampleMiddleware, first), Managed("Broken", sampleMiddleware, broken))
+	err := mc.Start()
+	assert.EqualError(t, err, "no config", "Start error does not match")
+	assert.Equal(t, []string{"start first", "start broken", "close first"}, events, "Started lifecycles not closed")
+}
+
+func Test_Chain_Shutdown_Deadline(t *testing.T) {
+	var events []string
+	stuck := &testLifecycle{name: "stuck", events: &events, block: make(chan struct{})}
+	mc := NewNamed(Managed("Stuck", sampleMiddleware, stuck))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, mc.Shutdown(ctx), context.DeadlineExceeded, "Deadline not reported")
+}
+
+func Test_Chain_RegisterOnShutdown(t *testing.T) {
+	m := NewMonitor()
+	var closed sync.WaitGroup
+	closed.Add(1)
+	mc := NewNamed(Managed("Monitor", m.Monitor, m)).AppendNamed(Managed("Done", sampleMiddleware, lifecycleFunc(closed.Done)))
+
+	server := httptest.NewUnstartedServer(mc.Then(&TestMonitorHandler{}))
+	mc.RegisterOnShutdown(server.Config)
+	server.Start()
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	assert.NoError(t, server.Config.Shutdown(context.Background()), "Shutdown failed")
+	server.Close()
+	closed.Wait()
+
+	m.lifecycle.Lock()
+	defer m.lifecycle.Unlock()
+	assert.Nil(t, m.stop, "Monitor not closed by server shutdown")
+}
+
+//lifecycleFunc calls the func when it is closed
+type lifecycleFunc func()
+
+func (f lifecycleFunc) Start() error {
+	return nil
+}
+
+func (f lifecycleFunc) Close(ctx context.Context) error {
+	f()
+	return nil
+}
+
+func Test_Monit_Lifecycle(t *testing.T) {
+	m := NewMonitor()
+	assert.NoError(t, m.Start(), "Starting a started monit failed")
+	assert.NoError(t, m.Close(context.Background()), "Close failed")
+	assert.NoError(t, m.Close(context.Background()), "Closing a closed monit failed")
+	assert.NotNil(t, m.Get(), "Data not available after close")
+}
+
+func Test_PanicLogger_Lifecycle(t *testing.T) {
+	p := NewPanicLogger()
+	mc := NewNamed(Managed("RecoverAndLogPanic", p.RecoverAndLogPanic, p))
+	server := httptest.NewServer(mc.Then(&TestPanicHandler{}))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, http.StatusInternalServerError, resp.StatusCode, "Panic not recovered")
+	assert.NoError(t, mc.Close(), "Close failed")
+	assert.Nil(t, p.watcher, "Config watcher not stopped")
+
+	assert.Equal(t, Lifecycle(defaultPanicLogger), New(RecoverAndLogPanic).List()[0].Lifecycle, "Default panic logger not attached")
+}
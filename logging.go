@@ -10,44 +10,90 @@ import (
 )
 
 //logger template is the type of string that will get logged to the console
-var loggerTemplate = "{{.StartTime}} || {{.Status}} || \t {{.Duration}} | {{.HostName}} | {{.Method}} | {{.Path}} \n"
+var loggerTemplate = "{{.StartTime}} || {{.Status}} || \t {{.Duration}} | {{.HostName}} | {{.Method}} | {{.Path}}{{if .RequestID}} | {{.RequestID}}{{end}} \n"
+
+var defaultLoggerTemplate = template.Must(template.New("logger_template").Parse(loggerTemplate))
 
 //loggerStruct stores the value of the logs
 type loggerStruct struct {
 	StartTime string
 	Status    int
 	Duration  time.Duration
-	HostName  string
-	Method    string
-	Path      string
+	//TimeToFirstByte is 0 for responses without a body
+	TimeToFirstByte time.Duration
+	//Size is the number of body bytes sent, ContentSize the number before compression
+	Size        int
+	ContentSize int
+	HostName    string
+	Method      string
+	Path        string
+	RequestID   string
+	ClientIP    string
+	Route       string
 }
 
 //Logger func handler for logging middleware
 func Logger(next http.Handler) http.Handler {
+	return logWith(defaultLoggerTemplate, next)
+}
+
+//NewLogger func creates a logging middleware which logs every request with the given template.
+//The template can use the fields StartTime, Status, Duration, TimeToFirstByte, Size, ContentSize, HostName, Method, Path,
+//RequestID, ClientIP and Route, the last three are read from the request store
+func NewLogger(format string) (Middleware, error) {
+	tem, err := template.New("logger_template").Parse(format)
+	if err != nil {
+		return nil, err
+	}
+	return func(next http.Handler) http.Handler {
+		return logWith(tem, next)
+	}, nil
+}
+
+func logWith(tem *template.Template, next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		start := time.Now()
 		//wrap the response writer to get the status code
 		//cant access status code from http.ResponseWriter
 		nrw := NewResponseWriter(w)
+		//attach a store so the values set by inner middlewares can be logged
+		r = WithStore(r)
+		//log once the handler is done, a panic is logged with 500 before it reaches the recovery middleware
+		completed := false
+		defer func() {
+			nrw.Finish()
+			status := nrw.Status()
+			if !completed {
+				status = http.StatusInternalServerError
+			}
+			logRequest(tem, nrw, r, status)
+		}()
 		//call the next handler
 		next.ServeHTTP(nrw, r)
-		//response := w.(ResponseWriter)
+		completed = true
+	})
+}
 
-		ls := &loggerStruct{
-			StartTime: start.Format(time.RFC3339),
-			Status:    nrw.Status(),
-			Duration:  time.Since(start),
-			HostName:  r.Host,
-			Method:    r.Method,
-			Path:      r.URL.Path,
-		}
+//logRequest writes the log line of a finished request
+func logRequest(tem *template.Template, nrw ResponseWriter, r *http.Request, status int) {
+	timing := nrw.Timing()
 
-		t := template.New("logger_template")
-		tem := template.Must(t.Parse(loggerTemplate))
+	ls := &loggerStruct{
+		StartTime:       timing.Start.Format(time.RFC3339),
+		Status:          status,
+		Duration:        timing.Total,
+		TimeToFirstByte: timing.TimeToFirstByte,
+		Size:            nrw.Size(),
+		ContentSize:     nrw.ContentSize(),
+		HostName:        r.Host,
+		Method:          r.Method,
+		Path:            r.URL.Path,
+		RequestID:       RequestID(r),
+		ClientIP:        ClientIP(r),
+		Route:           RoutePattern(r),
+	}
 
-		buf := &bytes.Buffer{}
-		tem.Execute(buf, ls)
+	buf := &bytes.Buffer{}
+	tem.Execute(buf, ls)
 
-		log.Println(html.UnescapeString(buf.String()))
-	})
+	log.Println(html.UnescapeString(buf.String()))
 }
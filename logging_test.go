@@ -2,8 +2,10 @@ package goat
 
 import (
 	"bytes"
+	"log"
 	"net/http"
 	"net/http/httptest"
+	"os"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -28,3 +30,17 @@ func Test_Logger(t *testing.T) {
 
 	assert.Equal(t, http.StatusNotFound, res.StatusCode, "Not Found")
 }
+
+func Test_Logger_Panic(t *testing.T) {
+	var logged bytes.Buffer
+	log.SetOutput(&logged)
+	defer log.SetOutput(os.Stderr)
+
+	logger, err := NewLogger("{{.Status}} {{.Path}}")
+	assert.NoError(t, err)
+	rr := httptest.NewRecorder()
+	New(Recovery, logger).Then(&TestPanicHandler{}).ServeHTTP(rr, httptest.NewRequest("GET", "/boom", nil))
+
+	assert.Equal(t, http.StatusInternalServerError, rr.Code, "Panic not recovered")
+	assert.Contains(t, logged.String(), "500 /boom", "Panic not logged with 500")
+}
@@ -1,6 +1,7 @@
 package goat
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"os"
@@ -16,24 +17,43 @@ type Monit struct {
 	TotalResponseCounts map[string]int
 	TotalResponseTime   time.Time
 	Pid                 int
+
+	//TotalTimeToFirstByte adds up the time to first byte of the responses with a body, BodyCount counts them
+	TotalTimeToFirstByte time.Duration
+	BodyCount            int
+	//TotalBytes counts the body bytes sent, TotalContentBytes the same bytes before compression
+	TotalBytes        int64
+	TotalContentBytes int64
+	//compression adds up what a Compression middleware inside the Monitor reports
+	compression compressionCounter
+
+	lifecycle sync.Mutex
+	stop      chan struct{}
+	done      chan struct{}
 }
 
 //MonitData struct
 type MonitData struct {
-	Pid                    int
-	UpTime                 string
-	UpTimeSec              float64
-	Time                   string
-	TimeUnix               int64
-	StatusCodeCount        map[string]int
-	TotalStatusCodeCount   map[string]int
-	Count                  int
-	TotalCount             int
-	TotalResponseTime      string
-	TotalResponseTimeSec   float64
-	AverageResponseTime    string
-	AverageResponseTimeSec float64
-	Memory                 string
+	Pid                       int
+	UpTime                    string
+	UpTimeSec                 float64
+	Time                      string
+	TimeUnix                  int64
+	StatusCodeCount           map[string]int
+	TotalStatusCodeCount      map[string]int
+	Count                     int
+	TotalCount                int
+	TotalResponseTime         string
+	TotalResponseTimeSec      float64
+	AverageResponseTime       string
+	AverageResponseTimeSec    float64
+	AverageTimeToFirstByte    string
+	AverageTimeToFirstByteSec float64
+	TotalBytes                int64
+	TotalContentBytes         int64
+	//Compression is nil unless a compression middleware runs inside the Monitor
+	Compression *CompressionStats
+	Memory      string
 }
 
 //Get func to get the Monit Data
@@ -61,20 +81,34 @@ func (m *Monit) Get() *MonitData {
 		avg := int64(totalResponseTime) / (int64)(totalCount)
 		averageResponseTime = time.Duration(avg)
 	}
+	averageTimeToFirstByte := time.Duration(0)
+	if m.BodyCount > 0 {
+		averageTimeToFirstByte = m.TotalTimeToFirstByte / time.Duration(m.BodyCount)
+	}
+	totalBytes, totalContentBytes := m.TotalBytes, m.TotalContentBytes
 	m.mu.RUnlock()
 
 	data := &MonitData{
-		Pid:                    m.Pid,
-		UpTime:                 upTime.String(),
-		UpTimeSec:              upTime.Seconds(),
-		Time:                   time.Now().String(),
-		TimeUnix:               time.Now().Unix(),
-		StatusCodeCount:        responseCounts,
-		TotalStatusCodeCount:   totalResponseCounts,
-		TotalResponseTime:      totalResponseTime.String(),
-		TotalResponseTimeSec:   totalResponseTime.Seconds(),
-		AverageResponseTimeSec: averageResponseTime.Seconds(),
-		AverageResponseTime:    averageResponseTime.String(),
+		Pid:                       m.Pid,
+		UpTime:                    upTime.String(),
+		UpTimeSec:                 upTime.Seconds(),
+		Time:                      time.Now().String(),
+		TimeUnix:                  time.Now().Unix(),
+		StatusCodeCount:           responseCounts,
+		TotalStatusCodeCount:      totalResponseCounts,
+		TotalResponseTime:         totalResponseTime.String(),
+		TotalResponseTimeSec:      totalResponseTime.Seconds(),
+		AverageResponseTimeSec:    averageResponseTime.Seconds(),
+		AverageResponseTime:       averageResponseTime.String(),
+		AverageTimeToFirstByte:    averageTimeToFirstByte.String(),
+		AverageTimeToFirstByteSec: averageTimeToFirstByte.Seconds(),
+		TotalBytes:                totalBytes,
+		TotalContentBytes:         totalContentBytes,
+	}
+
+	if !m.compression.empty() {
+		compression := m.compression.stats()
+		data.Compression = &compression
 	}
 
 	return data
@@ -87,7 +121,7 @@ func (m *Monit) ResetResponseCounts() {
 	m.ResponseCounts = map[string]int{}
 }
 
-//NewMonitor to get new monit object
+//NewMonitor to get new monit object, the goroutine resetting the response counts every second is already started
 func NewMonitor() *Monit {
 	monit := &Monit{
 		UpTime:              time.Now(),
@@ -96,28 +130,88 @@ func NewMonitor() *Monit {
 		TotalResponseCounts: map[string]int{},
 		TotalResponseTime:   time.Time{},
 	}
+	monit.Start()
 
-	go func() {
-		monit.ResetResponseCounts()
+	return monit
+}
 
-		time.Sleep(time.Second)
-	}()
+//Start func starts the goroutine which resets the response counts every second, calling it on a started monit does nothing
+func (m *Monit) Start() error {
+	m.lifecycle.Lock()
+	defer m.lifecycle.Unlock()
+	if m.stop != nil {
+		return nil
+	}
+	m.stop = make(chan struct{})
+	m.done = make(chan struct{})
 
-	return monit
+	go func(stop, done chan struct{}) {
+		defer close(done)
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				m.ResetResponseCounts()
+			case <-stop:
+				return
+			}
+		}
+	}(m.stop, m.done)
+	return nil
+}
+
+//Close func stops the reset goroutine and waits for it to exit, the collected data stays available through Get
+func (m *Monit) Close(ctx context.Context) error {
+	m.lifecycle.Lock()
+	stop, done := m.stop, m.done
+	m.stop, m.done = nil, nil
+	m.lifecycle.Unlock()
+
+	if stop == nil {
+		return nil
+	}
+	close(stop)
+	return waitContext(ctx, done)
 }
 
 //Monitor middleware to update the monit data
 func (m *Monit) Monitor(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		start := time.Now()
 		nrw := NewResponseWriter(w)
-		next.ServeHTTP(w, r)
-		responseTime := time.Since(start)
-		m.mu.Lock()
-		defer m.mu.Unlock()
-		statusCode := fmt.Sprintf("%d", nrw.Status())
-		m.ResponseCounts[statusCode]++
-		m.TotalResponseCounts[statusCode]++
-		m.TotalResponseTime = m.TotalResponseTime.Add(responseTime)
+		//attach a store so the compression middleware can report its result
+		r = WithStore(r)
+		//count once the handler is done, a panic is counted as 500 before it reaches the recovery middleware
+		completed := false
+		defer func() {
+			nrw.Finish()
+			status := nrw.Status()
+			if !completed {
+				status = http.StatusInternalServerError
+			}
+			m.record(nrw, r, status)
+		}()
+		next.ServeHTTP(nrw, r)
+		completed = true
 	})
 }
+
+//record adds a finished request to the monit data
+func (m *Monit) record(nrw ResponseWriter, r *http.Request, status int) {
+	if result, ok := Get(r, compressionResultKey); ok {
+		m.compression.add(result)
+	}
+	timing := nrw.Timing()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	statusCode := fmt.Sprintf("%d", status)
+	m.ResponseCounts[statusCode]++
+	m.TotalResponseCounts[statusCode]++
+	m.TotalResponseTime = m.TotalResponseTime.Add(timing.Total)
+	if nrw.Size() > 0 {
+		m.TotalTimeToFirstByte += timing.TimeToFirstByte
+		m.BodyCount++
+	}
+	m.TotalBytes += int64(nrw.Size())
+	m.TotalContentBytes += int64(nrw.ContentSize())
+}
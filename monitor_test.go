@@ -1,6 +1,7 @@
 package goat
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
@@ -20,6 +21,7 @@ func (h *TestMonitorHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 func Test_Monitor(t *testing.T) {
 	h := &TestMonitorHandler{}
 	m := NewMonitor()
+	defer m.Close(context.Background())
 	server := httptest.NewServer(m.Monitor(h))
 	defer server.Close()
 
@@ -30,3 +32,28 @@ func Test_Monitor(t *testing.T) {
 	log.Println(m.Get())
 	assert.Equal(t, http.StatusOK, resp.StatusCode, "Status Code does not match")
 }
+
+func Test_Monitor_Data(t *testing.T) {
+	m := NewMonitor()
+	defer m.Close(context.Background())
+	handler := m.Monitor(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+		fmt.Fprint(w, "short and stout")
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+
+	data := m.Get()
+	assert.Equal(t, 1, data.TotalStatusCodeCount["418"], "Status code not counted")
+	assert.Equal(t, int64(15), data.TotalBytes, "Bytes not counted")
+	assert.Equal(t, int64(15), data.TotalContentBytes, "Content bytes not counted")
+}
+
+func Test_Monitor_Panic(t *testing.T) {
+	m := NewMonitor()
+	defer m.Close(context.Background())
+	rr := httptest.NewRecorder()
+	New(Recovery, m.Monitor).Then(&TestPanicHandler{}).ServeHTTP(rr, httptest.NewRequest("GET", "/", nil))
+
+	assert.Equal(t, http.StatusInternalServerError, rr.Code, "Panic not recovered")
+	assert.Equal(t, 1, m.Get().TotalStatusCodeCount["500"], "Panic not counted as 500")
+}
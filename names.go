@@ -0,0 +1,100 @@
+package goat
+
+import (
+	"reflect"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+//nameRegistry maps the code pointer of a middleware func to the name it is listed under
+type nameRegistry struct {
+	mu    sync.RWMutex
+	names map[uintptr]string
+}
+
+var middlewareNames = &nameRegistry{
+	names: map[uintptr]string{},
+}
+
+func init() {
+	RegisterName(Logger, "Logger")
+	RegisterName(Recovery, "Recovery")
+	RegisterName(RecoverAndLogPanic, "RecoverAndLogPanic")
+	RegisterName(NoCache, "NoCache")
+	RegisterName(Compression, "Compression")
+	RegisterName(XSS, "XSS")
+	RegisterName(AssignRequestID, "AssignRequestID")
+	RegisterName(ContextStore, "ContextStore")
+	RegisterName(Decompression, "Decompression")
+	//closures returned by the constructors share one code pointer too, registering one of them names them all
+	if logger, err := NewLogger(loggerTemplate); err == nil {
+		RegisterName(logger, "Logger")
+	}
+	//method values share one code pointer for every receiver so a nil receiver is enough to register them
+	RegisterName((*CSPHandler)(nil).CSP, "CSP")
+	RegisterName((*CSPPolicySet)(nil).CSP, "CSP")
+	RegisterName((*Monit)(nil).Monitor, "Monitor")
+	RegisterName((*Compressor)(nil).Compression, "Compression")
+	RegisterName((*Decompressor)(nil).Decompression, "Decompression")
+}
+
+func funcPointer(middleware Middleware) uintptr {
+	return reflect.ValueOf(middleware).Pointer()
+}
+
+//RegisterName func registers a stable name for a middleware func, it is used whenever the func is added to a chain with New or Append.
+//Closures returned from the same func share one name, use Named to tell them apart
+func RegisterName(middleware Middleware, name string) {
+	if middleware == nil {
+		return
+	}
+	middlewareNames.mu.Lock()
+	defer middlewareNames.mu.Unlock()
+	middlewareNames.names[funcPointer(middleware)] = name
+}
+
+//MiddlewareName func returns the registered name of a middleware,
+//if no name was registered the func name reported by the runtime is used without its package path
+func MiddlewareName(middleware Middleware) string {
+	if middleware == nil {
+		return "<nil>"
+	}
+	pc := funcPointer(middleware)
+
+	middlewareNames.mu.RLock()
+	name, ok := middlewareNames.names[pc]
+	middlewareNames.mu.RUnlock()
+	if ok {
+		return name
+	}
+	if info, ok := describeConditional(middleware); ok {
+		return info.name
+	}
+
+	fn := runtime.FuncForPC(pc)
+	if fn == nil {
+		return "<unknown>"
+	}
+	return shortFuncName(fn.Name())
+}
+
+//shortFuncName strips the package path and the method value suffix from a runtime func name
+//e.g. github.com/com-redbus/goat.(*CSPHandler).CSP-fm becomes (*CSPHandler).CSP
+func shortFuncName(name string) string {
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+	if i := strings.Index(name, "."); i >= 0 {
+		name = name[i+1:]
+	}
+	return strings.TrimSuffix(name, "-fm")
+}
+
+func nameAll(middlewares []Middleware) []NamedMiddleware {
+	var named []NamedMiddleware
+	for _, m := range middlewares {
+		named = append(named, Managed(MiddlewareName(m), m, lifecycleFor(m)))
+	}
+	return named
+}
@@ -0,0 +1,47 @@
+package goat
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func sampleMiddleware(next http.Handler) http.Handler {
+	return next
+}
+
+func Test_MiddlewareName_BuiltIns(t *testing.T) {
+	csp, err := NewCSP(CSPOptions{DefaultSrc: []string{"'self'"}})
+	assert.NoError(t, err)
+	m := NewMonitor()
+
+	assert.Equal(t, "Logger", MiddlewareName(Logger), "Logger name not registered")
+	assert.Equal(t, "Recovery", MiddlewareName(Recovery), "Recovery name not registered")
+	assert.Equal(t, "NoCache", MiddlewareName(NoCache), "NoCache name not registered")
+	assert.Equal(t, "Compression", MiddlewareName(Compression), "Compression name not registered")
+	assert.Equal(t, "XSS", MiddlewareName(XSS), "XSS name not registered")
+	assert.Equal(t, "CSP", MiddlewareName(csp.CSP), "CSP name not registered")
+	assert.Equal(t, "Monitor", MiddlewareName(m.Monitor), "Monitor name not registered")
+}
+
+func Test_MiddlewareName_Fallback(t *testing.T) {
+	assert.Equal(t, "sampleMiddleware", MiddlewareName(sampleMiddleware), "Runtime name not used")
+	assert.Equal(t, "<nil>", MiddlewareName(nil), "nil middleware name does not match")
+}
+
+func Test_RegisterName(t *testing.T) {
+	custom := Middleware(func(next http.Handler) http.Handler { return next })
+	RegisterName(custom, "Custom")
+	assert.Equal(t, "Custom", MiddlewareName(custom), "Registered name not used")
+}
+
+func Test_Chain_Names(t *testing.T) {
+	mc := CommonMiddlewares().Append(Compression).AppendNamed(Named("Auth", sampleMiddleware))
+	assert.Equal(t, []string{"NoCache", "Recovery", "Logger", "Compression", "Auth"}, mc.Names(), "Chain names do not match")
+	assert.Equal(t, 5, mc.Len(), "Chain length does not match")
+
+	list := mc.List()
+	list[0].Name = "Changed"
+	assert.Equal(t, "NoCache", mc.Names()[0], "List should return a copy")
+}
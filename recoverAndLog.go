@@ -2,6 +2,7 @@ package goat
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -9,45 +10,152 @@ import (
 	"io/ioutil"
 	"net/http"
 	"os"
+	"path/filepath"
 	"runtime"
+	"sync"
 	"time"
 
 	"github.com/fsnotify/fsnotify"
 	"github.com/spf13/viper"
 )
 
-var logPath, remoteURL, agent string
-var isLogEnabled, isLogPushEnabledToRemote bool
+//panicLogSettings holds the attributes read from the config file
+type panicLogSettings struct {
+	logPath, remoteURL, agent              string
+	isLogEnabled, isLogPushEnabledToRemote bool
+}
 
-func populateConfigurableVariables(log *viper.Viper) {
-	isLogEnabled = log.GetBool("IsLogEnabled")
-	isLogPushEnabledToRemote = log.GetBool("IsLogPushEnabledToRemote")
-	logPath = log.GetString("LogPath")
-	remoteURL = log.GetString("RemoteUrl")
-	agent = log.GetString("Agent")
+func populateConfigurableVariables(log *viper.Viper) panicLogSettings {
+	return panicLogSettings{
+		isLogEnabled:             log.GetBool("IsLogEnabled"),
+		isLogPushEnabledToRemote: log.GetBool("IsLogPushEnabledToRemote"),
+		logPath:                  log.GetString("LogPath"),
+		remoteURL:                log.GetString("RemoteUrl"),
+		agent:                    log.GetString("Agent"),
+	}
 }
 
-func loadConfig() {
+//PanicLogger logs recovered panics to a file and/or a remote url as configured in the config file of the working directory.
+//The config file is watched for changes while the logger is started, Close stops the watcher
+//and waits for the panics that are still being pushed
+type PanicLogger struct {
+	mu       sync.Mutex
+	settings panicLogSettings
+	config   *viper.Viper
+	watcher  *fsnotify.Watcher
+	done     chan struct{}
+
+	//pushes hold a read lock while they run so Close can wait for them by taking the write lock
+	pushing sync.RWMutex
+}
+
+var defaultPanicLogger = NewPanicLogger()
+
+//NewPanicLogger func creates a PanicLogger, it reads the config file when it is started
+func NewPanicLogger() *PanicLogger {
+	return &PanicLogger{}
+}
+
+//Start func reads the config file and starts watching it for changes, calling it on a started logger does nothing
+func (p *PanicLogger) Start() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.watcher != nil {
+		return nil
+	}
+
 	log := viper.New()
 	log.SetConfigName("config")
 	log.AddConfigPath(".")
+	if err := log.ReadInConfig(); err != nil {
+		return fmt.Errorf("Fatal error config file: %s", err.Error())
+	}
 
-	err := log.ReadInConfig()
+	watcher, err := fsnotify.NewWatcher()
 	if err != nil {
-		panic(fmt.Errorf("Fatal error config file: %s", err.Error()))
+		return err
 	}
-	populateConfigurableVariables(log)
-	log.WatchConfig()
-	log.OnConfigChange(func(e fsnotify.Event) {
-		populateConfigurableVariables(log)
-	})
+	configFile := filepath.Clean(log.ConfigFileUsed())
+	if err := watcher.Add(filepath.Dir(configFile)); err != nil {
+		watcher.Close()
+		return err
+	}
+
+	p.config = log
+	p.settings = populateConfigurableVariables(log)
+	p.watcher = watcher
+	p.done = make(chan struct{})
+	go p.watch(watcher, configFile, p.done)
+	return nil
+}
+
+//watch reloads the settings whenever the config file is written or replaced
+func (p *PanicLogger) watch(watcher *fsnotify.Watcher, configFile string, done chan struct{}) {
+	defer close(done)
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			if filepath.Clean(event.Name) != configFile || event.Op&(fsnotify.Write|fsnotify.Create) == 0 {
+				continue
+			}
+			p.mu.Lock()
+			if p.config != nil && p.config.ReadInConfig() == nil {
+				p.settings = populateConfigurableVariables(p.config)
+			}
+			p.mu.Unlock()
+		case _, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+		}
+	}
+}
+
+//Close func stops watching the config file and waits for the running pushes and the watcher to finish
+func (p *PanicLogger) Close(ctx context.Context) error {
+	p.mu.Lock()
+	watcher, done := p.watcher, p.done
+	p.watcher, p.done = nil, nil
+	p.mu.Unlock()
+
+	var err error
+	if watcher != nil {
+		err = watcher.Close()
+		if waitErr := waitContext(ctx, done); waitErr != nil {
+			return waitErr
+		}
+	}
+
+	flushed := make(chan struct{})
+	go func() {
+		p.pushing.Lock()
+		p.pushing.Unlock()
+		close(flushed)
+	}()
+	if waitErr := waitContext(ctx, flushed); waitErr != nil {
+		return waitErr
+	}
+	return err
 }
 
-func pushError(input map[string]interface{}) {
+func (p *PanicLogger) currentSettings() panicLogSettings {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.settings
+}
+
+func (p *PanicLogger) pushError(input map[string]interface{}) {
+	p.pushing.RLock()
+	defer p.pushing.RUnlock()
+	settings := p.currentSettings()
+
 	payloadBytes, err := json.Marshal(input)
 	if err == nil {
-		if isLogEnabled {
-			f, err := os.OpenFile(logPath+time.Now().Format("2006-01-02")+".txt", os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+		if settings.isLogEnabled {
+			f, err := os.OpenFile(settings.logPath+time.Now().Format("2006-01-02")+".txt", os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
 			defer f.Close()
 			if err == nil {
 				stringMessage := string(payloadBytes)
@@ -56,9 +164,9 @@ func pushError(input map[string]interface{}) {
 				fmt.Println(err.Error())
 			}
 		}
-		if isLogPushEnabledToRemote {
+		if settings.isLogPushEnabledToRemote {
 			client := &http.Client{}
-			req, _ := http.NewRequest("POST", remoteURL, bytes.NewBuffer(payloadBytes))
+			req, _ := http.NewRequest("POST", settings.remoteURL, bytes.NewBuffer(payloadBytes))
 			req.Header.Set("Content-Type", "application/json")
 			client.Do(req)
 		}
@@ -66,36 +174,53 @@ func pushError(input map[string]interface{}) {
 }
 
 //RecoverAndLogPanic - It catches the unexpected panic in the application and helps in recovering from it.
-//If config file is placed in the working directory it logs data based on the attributes.
-func RecoverAndLogPanic(next http.Handler) http.Handler {
-	loadConfig()
+//The logger is started if it is not running yet, it panics if the config file can not be read
+func (p *PanicLogger) RecoverAndLogPanic(next http.Handler) http.Handler {
+	if err := p.Start(); err != nil {
+		panic(err)
+	}
 	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		//attach a store so the request id and user set by inner middlewares end up in the log
+		req = WithStore(req)
 		defer func() {
 			if err := recover(); err != nil {
-				input := collectErrorData(*req, err)
-				pushError(input)
-
-				res.WriteHeader(http.StatusInternalServerError)
-				res.Header().Set("Content-Type", "application/json")
-				errorText := fmt.Sprintf(" PANIC Defered : %v ", err)
-				res.Write([]byte(errorText))
+				input := collectErrorData(*req, err, p.currentSettings().agent)
+				p.pushError(input)
 
+				//respond the same way Recovery does
+				WriteError(res, req, recoveredError(err))
 			}
 		}()
 		next.ServeHTTP(res, req)
 	})
 }
 
+//RecoverAndLogGoRoutinePanic - It catches the unexpected panic in the goroutines and helps in recovering from it.
+//It has to be deferred directly in the goroutine
+func (p *PanicLogger) RecoverAndLogGoRoutinePanic(req http.Request) {
+	if err := recover(); err != nil {
+		input := collectErrorData(req, err, p.currentSettings().agent)
+		p.pushError(input)
+	}
+}
+
+//RecoverAndLogPanic - It catches the unexpected panic in the application and helps in recovering from it.
+//If config file is placed in the working directory it logs data based on the attributes.
+//It uses a shared PanicLogger which is closed by the Close of any chain it is part of
+func RecoverAndLogPanic(next http.Handler) http.Handler {
+	return defaultPanicLogger.RecoverAndLogPanic(next)
+}
+
 //RecoverAndLogGoRoutinePanic - It catches the unexpected panic in the goroutines and helps in recovering from it.
 //If config file is placed in the working directory it logs data based on the attributes.
 func RecoverAndLogGoRoutinePanic(req http.Request) {
 	if err := recover(); err != nil {
-		input := collectErrorData(req, err)
-		pushError(input)
+		input := collectErrorData(req, err, defaultPanicLogger.currentSettings().agent)
+		defaultPanicLogger.pushError(input)
 	}
 }
 
-func collectErrorData(req http.Request, err interface{}) map[string]interface{} {
+func collectErrorData(req http.Request, err interface{}, agent string) map[string]interface{} {
 	errorText := fmt.Sprintf("%v ", err)
 	bodyBytes, err := ioutil.ReadAll(req.Body)
 	bodyText := string(bodyBytes)
@@ -112,6 +237,12 @@ func collectErrorData(req http.Request, err interface{}) map[string]interface{}
 		"IP":      req.RemoteAddr,
 		"STACK":   stackTrace,
 	}
+	if id := RequestID(&req); id != "" {
+		input["REQUEST_ID"] = id
+	}
+	if user := User(&req); user != nil {
+		input["USER"] = fmt.Sprintf("%v", user)
+	}
 
 	return input
 }
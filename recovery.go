@@ -2,28 +2,30 @@ package goat
 
 import (
 	"errors"
+	"fmt"
 	"net/http"
 )
 
+//recoveredError turns the value returned by recover into an error
+func recoveredError(r interface{}) error {
+	switch t := r.(type) {
+	case string:
+		return errors.New(t)
+	case error:
+		return t
+	default:
+		return fmt.Errorf("unknown error: %v", t)
+	}
+}
+
 //Recovery middleware for catching panics in code globally
 func Recovery(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		var err error
 		defer func() {
 			//get hold of the panic
-			r := recover()
-			if r != nil {
-				switch t := r.(type) {
-				case string:
-					err = errors.New(t)
-				case error:
-					err = t
-				default:
-					err = errors.New("unknown error")
-				}
-				//need to decide what type of format to send to the response
-				//will work for now
-				http.Error(w, err.Error(), http.StatusInternalServerError)
+			if rec := recover(); rec != nil {
+				//panics are written the same way as errors returned by a HandlerE
+				WriteError(w, r, recoveredError(rec))
 			}
 		}()
 		//call the next handler
@@ -0,0 +1,346 @@
+package goat
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+)
+
+//MiddlewareFactory func creates a middleware from the options of a chain config entry.
+//Options are read with the getters of Options, errors recorded by the getters fail the entry
+//and every option the factory does not read is reported as unknown
+type MiddlewareFactory func(options *Options) (Middleware, error)
+
+type registeredFactory struct {
+	name    string
+	factory MiddlewareFactory
+}
+
+var middlewareRegistry = struct {
+	mu        sync.RWMutex
+	factories map[string]registeredFactory
+}{
+	factories: map[string]registeredFactory{},
+}
+
+func init() {
+	RegisterMiddleware("Logger", func(o *Options) (Middleware, error) {
+		m, err := NewLogger(o.String("format", loggerTemplate))
+		if err != nil {
+			return nil, o.Errorf("format", "%v", err)
+		}
+		return m, nil
+	})
+	RegisterMiddleware("Recovery", staticFactory(Recovery))
+	RegisterMiddleware("RecoverAndLogPanic", staticFactory(RecoverAndLogPanic))
+	RegisterMiddleware("NoCache", staticFactory(NoCache))
+	RegisterMiddleware("XSS", staticFactory(XSS))
+	RegisterMiddleware("AssignRequestID", staticFactory(AssignRequestID))
+	RegisterMiddleware("ContextStore", staticFactory(ContextStore))
+	RegisterMiddleware("Compression", func(o *Options) (Middleware, error) {
+		options := DefaultCompressionOptions()
+		if o.Has("level") {
+			options.Level = LevelOf(o.Int("level", DefaultCompression))
+		}
+		options.MinSize = o.Int("min-size", 0)
+		options.ContentTypes = o.Strings("content-types")
+		if o.Has("excluded-content-types") {
+			options.ExcludedContentTypes = o.Strings("excluded-content-types")
+		}
+		if err := o.Err(); err != nil {
+			return nil, err
+		}
+		c, err := NewCompressor(options)
+		if err != nil {
+			return nil, compressionConfigError(o, err)
+		}
+		return c.Compression, nil
+	})
+	RegisterMiddleware("Decompression", func(o *Options) (Middleware, error) {
+		d := NewDecompressor(DecompressionOptions{
+			MaxSize: int64(o.Int("max-size", DefaultMaxDecompressedSize)),
+		})
+		return d.Decompression, o.Err()
+	})
+	RegisterMiddleware("CSP", func(o *Options) (Middleware, error) {
+		if o.Has("plugin-types") {
+			//browsers dropped plugin-types, NewCSP ignores PluginTypes so the option would be silently lost
+			return nil, o.Errorf("plugin-types", "is no longer supported by browsers, block plugins with object-src 'none'")
+		}
+		directives := o.StringLists("directives")
+		csp, err := NewCSP(CSPOptions{
+			DefaultSrc:              o.Strings("default-src"),
+			ScriptSrc:               o.Strings("script-src"),
+			StyleSrc:                o.Strings("style-src"),
+			ImgSrc:                  o.Strings("img-src"),
+			ConnectSrc:              o.Strings("connect-src"),
+			FontSrc:                 o.Strings("font-src"),
+			ObjectSrc:               o.Strings("object-src"),
+			MediaSrc:                o.Strings("media-src"),
+			ChildSrc:                o.Strings("child-src"),
+			Sandbox:                 o.Strings("sandbox"),
+			ReportURI:               o.String("report-uri", ""),
+			FormAction:              o.Strings("form-action"),
+			FrameAncestors:          o.Strings("frame-ancestors"),
+			IsReportOnly:            o.Bool("report-only", false),
+			BaseURI:                 o.Strings("base-uri"),
+			WorkerSrc:               o.Strings("worker-src"),
+			ManifestSrc:             o.Strings("manifest-src"),
+			FrameSrc:                o.Strings("frame-src"),
+			ScriptSrcElem:           o.Strings("script-src-elem"),
+			ScriptSrcAttr:           o.Strings("script-src-attr"),
+			StyleSrcElem:            o.Strings("style-src-elem"),
+			StyleSrcAttr:            o.Strings("style-src-attr"),
+			UpgradeInsecureRequests: o.Bool("upgrade-insecure-requests", false),
+			BlockAllMixedContent:    o.Bool("block-all-mixed-content", false),
+			RequireTrustedTypesFor:  o.Strings("require-trusted-types-for"),
+			TrustedTypes:            o.Strings("trusted-types"),
+			ReportTo:                o.String("report-to", ""),
+			Directives:              directives,
+			Nonce:                   o.Bool("nonce", false),
+			NonceDirectives:         o.Strings("nonce-directives"),
+		})
+		if err := o.Err(); err != nil {
+			return nil, err
+		}
+		if err != nil {
+			return nil, cspConfigError(o, err, directives)
+		}
+		return csp.CSP, nil
+	})
+}
+
+//cspConfigError maps a CSPError to the option of the directive, directives of the directives option are reported under it
+func cspConfigError(o *Options, err error, directives map[string][]string) error {
+	var cspErr *CSPError
+	if errors.As(err, &cspErr) {
+		if _, ok := directives[cspErr.Directive]; ok {
+			return o.Errorf("directives."+cspErr.Directive, "%s", cspErr.Message)
+		}
+		return o.Errorf(cspErr.Directive, "%s", cspErr.Message)
+	}
+	return err
+}
+
+//compressionConfigError reports a *CompressionError under the option that caused it
+func compressionConfigError(o *Options, err error) error {
+	var compressionErr *CompressionError
+	if errors.As(err, &compressionErr) {
+		return o.Errorf(compressionErr.Option, "%s", compressionErr.Message)
+	}
+	return err
+}
+
+//staticFactory wraps a middleware that takes no options
+func staticFactory(middleware Middleware) MiddlewareFactory {
+	return func(o *Options) (Middleware, error) {
+		return middleware, nil
+	}
+}
+
+//RegisterMiddleware func makes a middleware available to chain config files under the given name.
+//Names are matched case insensitively, a later registration replaces an earlier one with the same name
+func RegisterMiddleware(name string, factory MiddlewareFactory) {
+	middlewareRegistry.mu.Lock()
+	defer middlewareRegistry.mu.Unlock()
+	middlewareRegistry.factories[strings.ToLower(name)] = registeredFactory{
+		name:    name,
+		factory: factory,
+	}
+}
+
+//RegisteredMiddlewares func returns the names of all middlewares available to chain config files
+func RegisteredMiddlewares() []string {
+	middlewareRegistry.mu.RLock()
+	defer middlewareRegistry.mu.RUnlock()
+	var names []string
+	for _, f := range middlewareRegistry.factories {
+		names = append(names, f.name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func lookupMiddleware(name string) (registeredFactory, bool) {
+	middlewareRegistry.mu.RLock()
+	defer middlewareRegistry.mu.RUnlock()
+	f, ok := middlewareRegistry.factories[strings.ToLower(name)]
+	return f, ok
+}
+
+//Options struct gives a MiddlewareFactory typed access to the options of a chain config entry.
+//A getter that finds a value of the wrong type records a *ConfigError and returns the default, Err returns the first one
+type Options struct {
+	path   string
+	values map[string]interface{}
+	read   map[string]bool
+	err    error
+}
+
+func newOptions(path string, values map[string]interface{}) *Options {
+	normalized := make(map[string]interface{}, len(values))
+	for k, v := range values {
+		normalized[strings.ToLower(k)] = v
+	}
+	return &Options{
+		path:   path,
+		values: normalized,
+		read:   map[string]bool{},
+	}
+}
+
+//Errorf func records an error for the option with the given key and returns it
+func (o *Options) Errorf(key string, format string, args ...interface{}) error {
+	err := &ConfigError{
+		Path:    o.path + "." + key,
+		Message: fmt.Sprintf(format, args...),
+	}
+	if o.err == nil {
+		o.err = err
+	}
+	return err
+}
+
+//Err func returns the first error recorded by the getters
+func (o *Options) Err() error {
+	return o.err
+}
+
+//Has func reports whether the option is set
+func (o *Options) Has(key string) bool {
+	_, ok := o.values[strings.ToLower(key)]
+	return ok
+}
+
+func (o *Options) get(key string) (interface{}, bool) {
+	key = strings.ToLower(key)
+	o.read[key] = true
+	v, ok := o.values[key]
+	return v, ok && v != nil
+}
+
+//String func returns the option as a string
+func (o *Options) String(key string, def string) string {
+	v, ok := o.get(key)
+	if !ok {
+		return def
+	}
+	s, ok := v.(string)
+	if !ok {
+		o.Errorf(key, "must be a string, got %T", v)
+		return def
+	}
+	return s
+}
+
+//Int func returns the option as an int, json numbers are accepted as long as they have no fraction
+func (o *Options) Int(key string, def int) int {
+	v, ok := o.get(key)
+	if !ok {
+		return def
+	}
+	switch n := v.(type) {
+	case int:
+		return n
+	case int64:
+		return int(n)
+	case uint64:
+		return int(n)
+	case float64:
+		if n == math.Trunc(n) {
+			return int(n)
+		}
+	}
+	o.Errorf(key, "must be an integer, got %v", v)
+	return def
+}
+
+//Bool func returns the option as a bool
+func (o *Options) Bool(key string, def bool) bool {
+	v, ok := o.get(key)
+	if !ok {
+		return def
+	}
+	b, ok := v.(bool)
+	if !ok {
+		o.Errorf(key, "must be true or false, got %v", v)
+		return def
+	}
+	return b
+}
+
+//Strings func returns the option as a list of strings, a single string is split on white space
+func (o *Options) Strings(key string) []string {
+	v, ok := o.get(key)
+	if !ok {
+		return nil
+	}
+	return o.toStrings(key, v)
+}
+
+//StringLists func returns the option as a map of lists of strings, every list is read like Strings.
+//Keys are lower cased like the option keys
+func (o *Options) StringLists(key string) map[string][]string {
+	v, ok := o.get(key)
+	if !ok {
+		return nil
+	}
+	entries, ok := v.(map[string]interface{})
+	if !ok {
+		o.Errorf(key, "must be a map of string lists, got %T", v)
+		return nil
+	}
+	lists := make(map[string][]string, len(entries))
+	for k, item := range entries {
+		name := strings.ToLower(k)
+		if item == nil {
+			lists[name] = nil
+			continue
+		}
+		before := o.err
+		values := o.toStrings(key+"."+name, item)
+		if o.err != before {
+			return nil
+		}
+		lists[name] = values
+	}
+	return lists
+}
+
+//toStrings converts the value of the option with the given key to a list of strings
+func (o *Options) toStrings(key string, v interface{}) []string {
+	switch list := v.(type) {
+	case string:
+		return strings.Fields(list)
+	case []string:
+		return list
+	case []interface{}:
+		values := make([]string, 0, len(list))
+		for i, item := range list {
+			s, ok := item.(string)
+			if !ok {
+				o.Errorf(fmt.Sprintf("%s[%d]", key, i), "must be a string, got %T", item)
+				return nil
+			}
+			values = append(values, s)
+		}
+		return values
+	}
+	o.Errorf(key, "must be a list of strings, got %T", v)
+	return nil
+}
+
+//unread returns the keys no getter asked for, sorted so errors are stable
+func (o *Options) unread() []string {
+	var keys []string
+	for k := range o.values {
+		if !o.read[k] {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,60 @@
+package goat
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Options_Getters(t *testing.T) {
+	o := newOptions("chains.api[0].options", map[string]interface{}{
+		"Level":   float64(6),
+		"enabled": true,
+		"sources": []interface{}{"'self'", "cdn.example.com"},
+		"inline":  "'self' 'unsafe-inline'",
+		"format":  "{{.Path}}",
+		"lists":   map[string]interface{}{"Fenced-Frame-Src": []interface{}{"https://ads.example.com"}, "upgrade": nil},
+	})
+	assert.Equal(t, 6, o.Int("level", 0), "Int does not match")
+	assert.Equal(t, true, o.Bool("enabled", false), "Bool does not match")
+	assert.Equal(t, []string{"'self'", "cdn.example.com"}, o.Strings("sources"), "Strings does not match")
+	assert.Equal(t, []string{"'self'", "'unsafe-inline'"}, o.Strings("inline"), "Strings from a string does not match")
+	assert.Equal(t, map[string][]string{"fenced-frame-src": {"https://ads.example.com"}, "upgrade": nil}, o.StringLists("lists"), "StringLists does not match")
+	assert.Equal(t, "fallback", o.String("missing", "fallback"), "Default not used")
+	assert.NoError(t, o.Err(), "Getters recorded an error")
+	assert.Equal(t, []string{"format"}, o.unread(), "Unread options do not match")
+
+	assert.Equal(t, 1, o.Int("format", 1), "Default not used for wrong type")
+	assert.EqualError(t, o.Err(), "chains.api[0].options.format: must be an integer, got {{.Path}}", "Type error does not match")
+}
+
+func Test_RegisterMiddleware(t *testing.T) {
+	RegisterMiddleware("Header", func(o *Options) (Middleware, error) {
+		name := o.String("name", "X-Goat")
+		value := o.String("value", "")
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set(name, value)
+				next.ServeHTTP(w, r)
+			})
+		}, nil
+	})
+	assert.Contains(t, RegisteredMiddlewares(), "Header", "Middleware not registered")
+
+	chains, err := BuildChains(map[string]interface{}{
+		"chains": map[string]interface{}{
+			"api": []interface{}{
+				map[string]interface{}{"name": "header", "options": map[string]interface{}{"value": "yes"}},
+			},
+		},
+	})
+	if !assert.NoError(t, err, "Building chains failed") {
+		return
+	}
+	rr := httptest.NewRecorder()
+	chains["api"].Then(&TestNoCacheHandler{}).ServeHTTP(rr, httptest.NewRequest("GET", "/", nil))
+	assert.Equal(t, "yes", rr.Header().Get("X-Goat"), "Registered middleware not used")
+	assert.Equal(t, []string{"Header"}, chains["api"].Names(), "Registered name not used")
+}
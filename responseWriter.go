@@ -3,55 +3,103 @@ package goat
 
 import (
 	"bufio"
-	"fmt"
+	"io"
 	"net"
 	"net/http"
+	"time"
 )
 
 // ResponseWriter is a wrapper around http.ResponseWriter that provides extra information about
 // the response. It is recommended that middleware handlers use this construct to wrap a responsewriter
-// if the functionality calls for it.
+// if the functionality calls for it. The optional interfaces http.Flusher, http.Hijacker, http.CloseNotifier,
+// http.Pusher and io.ReaderFrom are implemented exactly when the wrapped writer implements them.
 type ResponseWriter interface {
 	http.ResponseWriter
-	http.Flusher
 	// Status returns the status code of the response or 200 if the response has
 	// not been written (as this is the default response code in net/http)
 	Status() int
 	// Written returns whether or not the ResponseWriter has been written.
 	Written() bool
-	// Size returns the size of the response body.
+	// Size returns the size of the response body as written to the wrapped writer, after compression.
 	Size() int
+	// ContentSize returns the size of the response body before compression, it equals Size when
+	// the response is not compressed.
+	ContentSize() int
 	// Before allows for a function to be called before the ResponseWriter has been written to. This is
 	// useful for setting headers or any other operations that must happen before a response has been written.
 	Before(func(ResponseWriter))
+	// After allows for a function to be called once the handler is done, when Finish is called. Functions
+	// run in the reverse order they were added, like deferred calls.
+	After(func(ResponseWriter))
+	// Finish records the end of the response and runs the After functions. The middleware that created the
+	// ResponseWriter calls it once the next handler returns, later calls do nothing.
+	Finish()
+	// Timing returns the timing data recorded for the response so far.
+	Timing() Timing
+	// Unwrap returns the wrapped http.ResponseWriter, http.NewResponseController uses it to reach
+	// SetWriteDeadline and friends of the original writer.
+	Unwrap() http.ResponseWriter
+}
+
+// Timing holds the timing data of a response, all durations are measured from Start.
+type Timing struct {
+	// Start is the time the ResponseWriter was created.
+	Start time.Time
+	// TimeToHeaders is the time until the status and headers were written, 0 if they were not written yet.
+	TimeToHeaders time.Duration
+	// TimeToFirstByte is the time until the first body byte was written, 0 if no body was written yet.
+	TimeToFirstByte time.Duration
+	// WriteDuration is the time from the first body byte until the last write returned.
+	WriteDuration time.Duration
+	// Total is the time until Finish was called, or until now if the response is not finished yet.
+	Total time.Duration
 }
 
 type beforeFunc func(ResponseWriter)
 
+type afterFunc func(ResponseWriter)
+
 // NewResponseWriter creates a ResponseWriter that wraps an http.ResponseWriter
 func NewResponseWriter(rw http.ResponseWriter) ResponseWriter {
 	nrw := &responseWriter{
 		ResponseWriter: rw,
+		start:          time.Now(),
 	}
-
-	if _, ok := rw.(http.CloseNotifier); ok {
-		return &responseWriterCloseNotifer{nrw}
-	}
-
-	return nrw
+	nrw.self = wrapResponseWriter(nrw)
+	return nrw.self
 }
 
 type responseWriter struct {
 	http.ResponseWriter
 	status      int
 	size        int
+	contentSize int
+	//encoded is set when a compression middleware writes the encoded body through this writer,
+	//the size before compression is then reported by the compression middleware
+	encoded     bool
 	beforeFuncs []beforeFunc
+	afterFuncs  []afterFunc
+	finished    bool
+	//self is the writer handed out by NewResponseWriter, it is passed to the Before and After funcs
+	self ResponseWriter
+
+	start       time.Time
+	headersAt   time.Time
+	firstByteAt time.Time
+	lastWriteAt time.Time
+	finishedAt  time.Time
 }
 
 func (rw *responseWriter) WriteHeader(s int) {
+	if informational(s) {
+		// 1xx responses like 103 Early Hints are sent ahead of the final response and do not count as written
+		rw.ResponseWriter.WriteHeader(s)
+		return
+	}
 	rw.status = s
 	rw.callBefore()
 	rw.ResponseWriter.WriteHeader(s)
+	rw.headersAt = time.Now()
 }
 
 func (rw *responseWriter) Write(b []byte) (int, error) {
@@ -60,10 +108,75 @@ func (rw *responseWriter) Write(b []byte) (int, error) {
 		rw.WriteHeader(http.StatusOK)
 	}
 	size, err := rw.ResponseWriter.Write(b)
-	rw.size += size
+	rw.wrote(size)
 	return size, err
 }
 
+// informational reports whether the status is a 1xx status that is followed by the final response,
+// 101 Switching Protocols is final
+func informational(status int) bool {
+	return status >= 100 && status < 200 && status != http.StatusSwitchingProtocols
+}
+
+// bodyAllowed reports whether a response with the status may carry a body
+func bodyAllowed(status int) bool {
+	return status >= 200 && status != http.StatusNoContent && status != http.StatusNotModified
+}
+
+// wrote records size body bytes written to the wrapped writer
+func (rw *responseWriter) wrote(size int) {
+	if size == 0 {
+		return
+	}
+	now := time.Now()
+	if rw.firstByteAt.IsZero() {
+		rw.firstByteAt = now
+	}
+	rw.lastWriteAt = now
+	rw.size += size
+	if !rw.encoded {
+		rw.contentSize += size
+	}
+}
+
+func (rw *responseWriter) ContentSize() int {
+	return rw.contentSize
+}
+
+func (rw *responseWriter) After(after func(ResponseWriter)) {
+	rw.afterFuncs = append(rw.afterFuncs, after)
+}
+
+func (rw *responseWriter) Finish() {
+	if rw.finished {
+		return
+	}
+	rw.finished = true
+	rw.finishedAt = time.Now()
+	for i := len(rw.afterFuncs) - 1; i >= 0; i-- {
+		rw.afterFuncs[i](rw.self)
+	}
+}
+
+func (rw *responseWriter) Timing() Timing {
+	timing := Timing{
+		Start: rw.start,
+	}
+	if !rw.headersAt.IsZero() {
+		timing.TimeToHeaders = rw.headersAt.Sub(rw.start)
+	}
+	if !rw.firstByteAt.IsZero() {
+		timing.TimeToFirstByte = rw.firstByteAt.Sub(rw.start)
+		timing.WriteDuration = rw.lastWriteAt.Sub(rw.firstByteAt)
+	}
+	if rw.finished {
+		timing.Total = rw.finishedAt.Sub(rw.start)
+	} else {
+		timing.Total = time.Since(rw.start)
+	}
+	return timing
+}
+
 func (rw *responseWriter) Status() int {
 	return rw.status
 }
@@ -80,35 +193,115 @@ func (rw *responseWriter) Before(before func(ResponseWriter)) {
 	rw.beforeFuncs = append(rw.beforeFuncs, before)
 }
 
-func (rw *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
-	hijacker, ok := rw.ResponseWriter.(http.Hijacker)
-	if !ok {
-		return nil, nil, fmt.Errorf("the ResponseWriter doesn't support the Hijacker interface")
-	}
-	return hijacker.Hijack()
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
 }
 
 func (rw *responseWriter) callBefore() {
 	for i := len(rw.beforeFuncs) - 1; i >= 0; i-- {
-		rw.beforeFuncs[i](rw)
+		rw.beforeFuncs[i](rw.self)
 	}
 }
 
-func (rw *responseWriter) Flush() {
-	flusher, ok := rw.ResponseWriter.(http.Flusher)
-	if ok {
-		if !rw.Written() {
-			// The status will be StatusOK if WriteHeader has not been called yet
-			rw.WriteHeader(http.StatusOK)
+// contentRecorder is implemented by the goat writers so a compression middleware can report
+// the size of the body before compression to the writers below it
+type contentRecorder interface {
+	setEncoded()
+	addContent(size int)
+}
+
+func (rw *responseWriter) setEncoded() {
+	rw.encoded = true
+}
+
+func (rw *responseWriter) addContent(size int) {
+	rw.contentSize += size
+}
+
+// eachContentRecorder calls f for every goat writer in the chain of writers w unwraps to
+func eachContentRecorder(w http.ResponseWriter, f func(contentRecorder)) {
+	for w != nil {
+		if recorder, ok := w.(contentRecorder); ok {
+			f(recorder)
 		}
-		flusher.Flush()
+		unwrapper, ok := w.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			return
+		}
+		w = unwrapper.Unwrap()
 	}
 }
 
-type responseWriterCloseNotifer struct {
-	*responseWriter
+//baseWriter is implemented by the goat writers, the optional interfaces call its unexported methods
+type baseWriter interface {
+	ResponseWriter
+	contentRecorder
+	flush()
+	hijack() (net.Conn, *bufio.ReadWriter, error)
+	readFrom(src io.Reader) (int64, error)
+}
+
+func (rw *responseWriter) flush() {
+	if !rw.Written() {
+		// The status will be StatusOK if WriteHeader has not been called yet
+		rw.WriteHeader(http.StatusOK)
+	}
+	rw.ResponseWriter.(http.Flusher).Flush()
+}
+
+func (rw *responseWriter) hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return rw.ResponseWriter.(http.Hijacker).Hijack()
+}
+
+//readFrom keeps sendfile working for files served through the wrapper
+func (rw *responseWriter) readFrom(src io.Reader) (int64, error) {
+	if !rw.Written() {
+		// The status will be StatusOK if WriteHeader has not been called yet
+		rw.WriteHeader(http.StatusOK)
+	}
+	n, err := rw.ResponseWriter.(io.ReaderFrom).ReadFrom(src)
+	rw.wrote(int(n))
+	return n, err
+}
+
+//the optional interfaces are implemented by separate types, wrapResponseWriter only embeds those the wrapped writer supports
+
+type responseFlusher struct {
+	baseWriter
+}
+
+func (rw responseFlusher) Flush() {
+	rw.flush()
+}
+
+type responseHijacker struct {
+	baseWriter
+}
+
+func (rw responseHijacker) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return rw.hijack()
+}
+
+type responseCloseNotifier struct {
+	baseWriter
+}
+
+func (rw responseCloseNotifier) CloseNotify() <-chan bool {
+	return rw.Unwrap().(http.CloseNotifier).CloseNotify()
+}
+
+type responsePusher struct {
+	baseWriter
+}
+
+func (rw responsePusher) Push(target string, opts *http.PushOptions) error {
+	return rw.Unwrap().(http.Pusher).Push(target, opts)
+}
+
+type responseReaderFrom struct {
+	baseWriter
 }
 
-func (rw *responseWriterCloseNotifer) CloseNotify() <-chan bool {
-	return rw.ResponseWriter.(http.CloseNotifier).CloseNotify()
+func (rw responseReaderFrom) ReadFrom(src io.Reader) (int64, error) {
+	return rw.readFrom(src)
 }
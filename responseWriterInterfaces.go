@@ -0,0 +1,38 @@
+package goat
+
+//go:generate go run genResponseWriterWrappers.go
+
+import (
+	"io"
+	"net/http"
+)
+
+//optional interfaces of an http.ResponseWriter which the wrapper keeps
+const (
+	flusherFlag = 1 << iota
+	hijackerFlag
+	closeNotifierFlag
+	pusherFlag
+	readerFromFlag
+)
+
+//interfaceFlags returns the optional interfaces the writer implements
+func interfaceFlags(w http.ResponseWriter) int {
+	flags := 0
+	if _, ok := w.(http.Flusher); ok {
+		flags |= flusherFlag
+	}
+	if _, ok := w.(http.Hijacker); ok {
+		flags |= hijackerFlag
+	}
+	if _, ok := w.(http.CloseNotifier); ok {
+		flags |= closeNotifierFlag
+	}
+	if _, ok := w.(http.Pusher); ok {
+		flags |= pusherFlag
+	}
+	if _, ok := w.(io.ReaderFrom); ok {
+		flags |= readerFromFlag
+	}
+	return flags
+}
@@ -0,0 +1,227 @@
+package goat
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+//plainWriter implements none of the optional interfaces
+type plainWriter struct {
+	header http.Header
+	body   strings.Builder
+	status int
+}
+
+func (w *plainWriter) Header() http.Header {
+	if w.header == nil {
+		w.header = http.Header{}
+	}
+	return w.header
+}
+
+func (w *plainWriter) Write(b []byte) (int, error) {
+	return w.body.Write(b)
+}
+
+func (w *plainWriter) WriteHeader(status int) {
+	w.status = status
+}
+
+//fullWriter implements all optional interfaces and the deadline method http.ResponseController looks for
+type fullWriter struct {
+	plainWriter
+	flushed  bool
+	readFrom bool
+	pushed   string
+	deadline time.Time
+}
+
+func (w *fullWriter) Flush() {
+	w.flushed = true
+}
+
+func (w *fullWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return nil, nil, nil
+}
+
+func (w *fullWriter) CloseNotify() <-chan bool {
+	return make(chan bool)
+}
+
+func (w *fullWriter) Push(target string, opts *http.PushOptions) error {
+	w.pushed = target
+	return nil
+}
+
+func (w *fullWriter) ReadFrom(src io.Reader) (int64, error) {
+	w.readFrom = true
+	return io.Copy(&w.body, src)
+}
+
+func (w *fullWriter) SetWriteDeadline(deadline time.Time) error {
+	w.deadline = deadline
+	return nil
+}
+
+type writerInterfaces struct {
+	flusher, hijacker, closeNotifier, pusher, readerFrom bool
+}
+
+func interfacesOf(w http.ResponseWriter) writerInterfaces {
+	var i writerInterfaces
+	_, i.flusher = w.(http.Flusher)
+	_, i.hijacker = w.(http.Hijacker)
+	_, i.closeNotifier = w.(http.CloseNotifier)
+	_, i.pusher = w.(http.Pusher)
+	_, i.readerFrom = w.(io.ReaderFrom)
+	return i
+}
+
+func Test_ResponseWriter_KeepsInterfaces(t *testing.T) {
+	writers := map[string]http.ResponseWriter{
+		"plain":    &plainWriter{},
+		"recorder": httptest.NewRecorder(),
+		"full":     &fullWriter{},
+	}
+	for name, w := range writers {
+		assert.Equal(t, interfacesOf(w), interfacesOf(NewResponseWriter(w)), "Interfaces of the %s writer not kept", name)
+	}
+}
+
+func Test_ResponseWriter_Passthrough(t *testing.T) {
+	inner := &fullWriter{}
+	rw := NewResponseWriter(inner)
+
+	n, err := rw.(io.ReaderFrom).ReadFrom(strings.NewReader("hello"))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5), n, "ReadFrom size does not match")
+	assert.True(t, inner.readFrom, "ReadFrom not passed to the wrapped writer")
+	assert.Equal(t, http.StatusOK, rw.Status(), "Status not set by ReadFrom")
+	assert.Equal(t, 5, rw.Size(), "Size not counted by ReadFrom")
+
+	rw.(http.Flusher).Flush()
+	assert.True(t, inner.flushed, "Flush not passed to the wrapped writer")
+
+	assert.NoError(t, rw.(http.Pusher).Push("/app.js", nil))
+	assert.Equal(t, "/app.js", inner.pushed, "Push not passed to the wrapped writer")
+
+	assert.Equal(t, inner, rw.Unwrap(), "Unwrap does not return the wrapped writer")
+	deadline := time.Now().Add(time.Second)
+	assert.NoError(t, http.NewResponseController(rw).SetWriteDeadline(deadline))
+	assert.Equal(t, deadline, inner.deadline, "Deadline not set through Unwrap")
+}
+
+func Test_ResponseWriter_FlushWritesHeader(t *testing.T) {
+	rr := httptest.NewRecorder()
+	rw := NewResponseWriter(rr)
+	called := false
+	rw.Before(func(ResponseWriter) {
+		called = true
+	})
+	rw.(http.Flusher).Flush()
+	assert.True(t, called, "Before func not called on Flush")
+	assert.True(t, rr.Flushed, "Recorder not flushed")
+	assert.Equal(t, http.StatusOK, rw.Status(), "Status not set by Flush")
+}
+
+func Test_ResponseWriter_After(t *testing.T) {
+	rw := NewResponseWriter(httptest.NewRecorder())
+	var calls []string
+	rw.After(func(w ResponseWriter) {
+		calls = append(calls, "first")
+		assert.Equal(t, http.StatusCreated, w.Status(), "After func called before the response was written")
+	})
+	rw.After(func(ResponseWriter) {
+		calls = append(calls, "second")
+	})
+	rw.WriteHeader(http.StatusCreated)
+	assert.Empty(t, calls, "After funcs called before Finish")
+	rw.Finish()
+	rw.Finish()
+	assert.Equal(t, []string{"second", "first"}, calls, "After funcs not called once in reverse order")
+}
+
+func Test_ResponseWriter_Timing(t *testing.T) {
+	rw := NewResponseWriter(httptest.NewRecorder())
+	assert.Zero(t, rw.Timing().TimeToHeaders, "Headers not written yet")
+
+	time.Sleep(2 * time.Millisecond)
+	rw.WriteHeader(http.StatusOK)
+	time.Sleep(2 * time.Millisecond)
+	rw.Write([]byte("a"))
+	time.Sleep(2 * time.Millisecond)
+	rw.Write([]byte("b"))
+	rw.Finish()
+
+	timing := rw.Timing()
+	assert.True(t, timing.TimeToHeaders >= 2*time.Millisecond, "Time to headers too short")
+	assert.True(t, timing.TimeToFirstByte > timing.TimeToHeaders, "Time to first byte must come after the headers")
+	assert.True(t, timing.WriteDuration >= 2*time.Millisecond, "Write duration too short")
+	assert.True(t, timing.Total >= timing.TimeToFirstByte+timing.WriteDuration, "Total too short")
+	assert.Equal(t, timing, rw.Timing(), "Timing changed after Finish")
+}
+
+func Test_ResponseWriter_ContentSizeUnderCompression(t *testing.T) {
+	body := strings.Repeat("goat ", 200)
+	var outer ResponseWriter
+	measure := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			outer = NewResponseWriter(w)
+			next.ServeHTTP(outer, r)
+			outer.Finish()
+		})
+	}
+	handler := New(measure, Compression).ThenFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(body))
+	})
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	handler.ServeHTTP(rr, req)
+	assert.Equal(t, len(body), outer.ContentSize(), "Content size must be the uncompressed size")
+	assert.Equal(t, rr.Body.Len(), outer.Size(), "Size must be the compressed size")
+	assert.True(t, outer.Size() < outer.ContentSize(), "Body not compressed")
+
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "/", nil))
+	assert.Equal(t, len(body), outer.Size(), "Size does not match without compression")
+	assert.Equal(t, outer.Size(), outer.ContentSize(), "Content size must equal size without compression")
+}
+
+func Test_ResponseWriter_FinishOnPanic(t *testing.T) {
+	middlewares := map[string]Middleware{
+		"Logger":        Logger,
+		"Monitor":       NewMonitor().Monitor,
+		"Compression":   Compression,
+		"Decompression": Decompression,
+	}
+	for name, m := range middlewares {
+		var finished bool
+		var timing Timing
+		h := New(Recovery, m).Then(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.(ResponseWriter).After(func(rw ResponseWriter) {
+				finished = true
+				timing = rw.Timing()
+			})
+			panic("handler failed")
+		}))
+		req := httptest.NewRequest("POST", "/", bytes.NewReader(gzipped("body")))
+		req.Header.Set("Content-Encoding", "gzip")
+		req.Header.Set("Accept-Encoding", "gzip")
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusInternalServerError, rr.Code, "Panic behind %s not recovered", name)
+		assert.True(t, finished, "After funcs of %s not run on panic", name)
+		assert.NotZero(t, timing.Total, "Timing of %s not finished on panic", name)
+	}
+}
@@ -0,0 +1,276 @@
+package goat
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+//precompressedSuffixes maps the codings a StaticHandler looks for to the suffix of their sibling files, preferred first
+var precompressedSuffixes = []struct {
+	coding string
+	suffix string
+}{
+	{"br", ".br"},
+	{"gzip", ".gz"},
+}
+
+//fingerprintPattern matches the last dash or dot separated segment before the extension, like 3f9a2c1d in app.3f9a2c1d.js
+var fingerprintPattern = regexp.MustCompile(`[.-]([0-9A-Za-z_]+)\.[0-9A-Za-z]+$`)
+
+//hexHashPattern matches the lengths hex content hashes are usually cut to by bundlers
+var hexHashPattern = regexp.MustCompile(`^([0-9a-f]{8}|[0-9a-f]{12}|[0-9a-f]{16}|[0-9a-f]{20}|[0-9a-f]{32}|[0-9a-f]{40}|[0-9a-f]{64})$`)
+
+//base64HashPattern matches the 8 character base64url hashes of Vite and Rollup
+var base64HashPattern = regexp.MustCompile(`^[0-9A-Za-z_]{8}$`)
+
+//Fingerprinted func reports whether a file name carries a content hash, like app.3f9a2c1d.js or chunk-BX7fw9Qa.css.
+//A hex hash has a fixed length of 8, 12, 16, 20, 32, 40 or 64 characters with digits and letters, a base64url hash has
+//8 characters with digits, lower and upper case letters, so names like app.20241231.js or site-header2024.css are no hashes.
+//It is the default of StaticOptions.Fingerprinted
+func Fingerprinted(name string) bool {
+	match := fingerprintPattern.FindStringSubmatch(path.Base(name))
+	if match == nil {
+		return false
+	}
+	hash := match[1]
+	hasDigit := strings.ContainsAny(hash, "0123456789")
+	if hexHashPattern.MatchString(hash) {
+		return hasDigit && strings.ContainsAny(hash, "abcdef")
+	}
+	return base64HashPattern.MatchString(hash) && hasDigit &&
+		strings.ContainsAny(hash, "abcdefghijklmnopqrstuvwxyz") && strings.ContainsAny(hash, "ABCDEFGHIJKLMNOPQRSTUVWXYZ")
+}
+
+//StaticOptions struct configures a StaticHandler
+type StaticOptions struct {
+	//Index is served for directories, index.html if empty
+	Index string
+	//SPAFallback serves the root Index for paths without a file extension that match no file,
+	//so the client side routes of a single page app load the app
+	SPAFallback bool
+	//Fingerprinted tells which files carry a content hash in their name, Fingerprinted is used if nil
+	Fingerprinted func(name string) bool
+	//ImmutableCacheControl is sent for fingerprinted files, "public, max-age=31536000, immutable" if empty
+	ImmutableCacheControl string
+	//CacheControl is sent for every other file, "no-cache" if empty so browsers revalidate with the ETag
+	CacheControl string
+}
+
+//StaticHandler struct serves the files of an fs.FS, it prefers precompressed .br and .gz siblings of a file
+//when the client accepts them and sends ETag, Vary and Cache-Control headers. Range requests and conditional
+//requests are answered by http.ServeContent
+type StaticHandler struct {
+	fsys    fs.FS
+	options StaticOptions
+	//etags caches the ETag of every file, keyed by name, size and modification time
+	etags sync.Map
+}
+
+type etagKey struct {
+	name    string
+	size    int64
+	modTime time.Time
+}
+
+//NewStatic func creates a StaticHandler for the files of fsys, e.g. os.DirFS("public") or an embed.FS
+func NewStatic(fsys fs.FS, options StaticOptions) *StaticHandler {
+	if options.Index == "" {
+		options.Index = "index.html"
+	}
+	if options.Fingerprinted == nil {
+		options.Fingerprinted = Fingerprinted
+	}
+	if options.ImmutableCacheControl == "" {
+		options.ImmutableCacheControl = "public, max-age=31536000, immutable"
+	}
+	if options.CacheControl == "" {
+		options.CacheControl = "no-cache"
+	}
+	return &StaticHandler{
+		fsys:    fsys,
+		options: options,
+	}
+}
+
+//ServeHTTP func serves the file named by the request path
+func (s *StaticHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		WriteError(w, r, NewHTTPError(http.StatusMethodNotAllowed, ""))
+		return
+	}
+
+	name, ok := s.resolve(r.URL.Path)
+	if !ok {
+		WriteError(w, r, NotFound(""))
+		return
+	}
+
+	headers := w.Header()
+	//the response depends on Accept-Encoding whenever a precompressed sibling could be picked
+	addVary(headers, "Accept-Encoding")
+	servedName, coding := s.precompressed(name, r.Header.Get("Accept-Encoding"))
+
+	f, err := s.fsys.Open(servedName)
+	if err != nil {
+		WriteError(w, r, NotFound(""))
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		WriteError(w, r, internalError(err))
+		return
+	}
+	content, err := readSeeker(f)
+	if err != nil {
+		WriteError(w, r, internalError(err))
+		return
+	}
+
+	contentType, err := s.contentType(name, servedName, content)
+	if err != nil {
+		WriteError(w, r, internalError(err))
+		return
+	}
+	headers.Set("Content-Type", contentType)
+	if coding != "" {
+		headers.Set("Content-Encoding", coding)
+	}
+	etag, err := s.etag(servedName, info, content)
+	if err != nil {
+		WriteError(w, r, internalError(err))
+		return
+	}
+	headers.Set("ETag", etag)
+	if s.options.Fingerprinted(name) {
+		headers.Set("Cache-Control", s.options.ImmutableCacheControl)
+	} else {
+		headers.Set("Cache-Control", s.options.CacheControl)
+	}
+
+	http.ServeContent(w, r, name, info.ModTime(), content)
+}
+
+//resolve maps the request path to the name of a regular file, falling back to the index of directories and of the app
+func (s *StaticHandler) resolve(urlPath string) (string, bool) {
+	name := strings.TrimPrefix(path.Clean("/"+urlPath), "/")
+	if name == "" {
+		name = "."
+	}
+	info, err := fs.Stat(s.fsys, name)
+	if err == nil && info.IsDir() {
+		name = path.Join(name, s.options.Index)
+		info, err = fs.Stat(s.fsys, name)
+	}
+	if err == nil && info.Mode().IsRegular() {
+		return name, true
+	}
+	if errors.Is(err, fs.ErrNotExist) && s.options.SPAFallback && path.Ext(name) == "" {
+		if info, err := fs.Stat(s.fsys, s.options.Index); err == nil && info.Mode().IsRegular() {
+			return s.options.Index, true
+		}
+	}
+	return "", false
+}
+
+//precompressed returns the sibling of the file in the coding the client prefers, or the file itself with no coding
+func (s *StaticHandler) precompressed(name string, acceptEncoding string) (string, string) {
+	var codings []string
+	siblings := map[string]string{}
+	for _, p := range precompressedSuffixes {
+		if info, err := fs.Stat(s.fsys, name+p.suffix); err == nil && info.Mode().IsRegular() {
+			codings = append(codings, p.coding)
+			siblings[p.coding] = name + p.suffix
+		}
+	}
+	coding := negotiateCoding(acceptEncoding, codings)
+	if coding == "" {
+		return name, ""
+	}
+	return siblings[coding], coding
+}
+
+//contentType returns the type of the original file, from its extension or by sniffing it
+func (s *StaticHandler) contentType(name string, servedName string, content io.ReadSeeker) (string, error) {
+	if contentType := mime.TypeByExtension(path.Ext(name)); contentType != "" {
+		return contentType, nil
+	}
+	if name != servedName {
+		//the served file is compressed, sniff the original instead
+		f, err := s.fsys.Open(name)
+		if err != nil {
+			return "", err
+		}
+		defer f.Close()
+		content, err = readSeeker(f)
+		if err != nil {
+			return "", err
+		}
+	}
+	buf := make([]byte, 512)
+	n, _ := io.ReadFull(content, buf)
+	if _, err := content.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	return http.DetectContentType(buf[:n]), nil
+}
+
+//etag returns the strong ETag of the file, a hash of its content which is computed once per version of the file
+func (s *StaticHandler) etag(name string, info fs.FileInfo, content io.ReadSeeker) (string, error) {
+	key := etagKey{name: name, size: info.Size(), modTime: info.ModTime()}
+	if etag, ok := s.etags.Load(key); ok {
+		return etag.(string), nil
+	}
+	hash := sha256.New()
+	if _, err := io.Copy(hash, content); err != nil {
+		return "", err
+	}
+	if _, err := content.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	//the hash of the served file differs between the codings, so every representation has its own ETag
+	etag := `"` + hex.EncodeToString(hash.Sum(nil)[:16]) + `"`
+	s.etags.Store(key, etag)
+	return etag, nil
+}
+
+//internalError answers a failure of the file system with a plain 500, the error itself is kept for logs only
+func internalError(err error) error {
+	return &HTTPError{Status: http.StatusInternalServerError, Err: err}
+}
+
+//readSeeker returns the file as an io.ReadSeeker, files which can not seek are read into memory
+func readSeeker(f fs.File) (io.ReadSeeker, error) {
+	if rs, ok := f.(io.ReadSeeker); ok {
+		return rs, nil
+	}
+	b, err := io.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(b), nil
+}
+
+//addVary adds value to the Vary header unless a middleware like Compression already listed it
+func addVary(headers http.Header, value string) {
+	for _, vary := range headers.Values("Vary") {
+		for _, field := range strings.Split(vary, ",") {
+			if strings.EqualFold(strings.TrimSpace(field), value) {
+				return
+			}
+		}
+	}
+	headers.Add("Vary", value)
+}
@@ -0,0 +1,128 @@
+package goat
+
+import (
+	"errors"
+	"io/fs"
+	"net/http"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func staticFS() fstest.MapFS {
+	modTime := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	file := func(data string) *fstest.MapFile {
+		return &fstest.MapFile{Data: []byte(data), ModTime: modTime}
+	}
+	return fstest.MapFS{
+		"index.html":                file("<html>app</html>"),
+		"assets/app.3f9a2c1d.js":    file("console.log('app')"),
+		"assets/app.3f9a2c1d.js.gz": file(string(gzipped("console.log('app')"))),
+		"assets/app.3f9a2c1d.js.br": file("brotli bytes"),
+		"docs/readme.txt":           file("0123456789"),
+		"data/blob":                 file("\x89PNG\r\n\x1a\n rest of the image"),
+	}
+}
+
+func Test_Static_Precompressed(t *testing.T) {
+	h := NewStatic(staticFS(), StaticOptions{})
+
+	br := serve(h, "GET", "/assets/app.3f9a2c1d.js", nil, http.Header{"Accept-Encoding": {"gzip, br"}})
+	assert.Equal(t, http.StatusOK, br.Code, "Status does not match")
+	assert.Equal(t, "br", br.Header().Get("Content-Encoding"), "Brotli sibling not preferred")
+	assert.Equal(t, "brotli bytes", br.Body.String(), "Body does not match")
+	assert.Equal(t, "text/javascript; charset=utf-8", br.Header().Get("Content-Type"), "Content-Type of the original not used")
+	assert.Equal(t, "Accept-Encoding", br.Header().Get("Vary"), "Vary header missing")
+	assert.Equal(t, "public, max-age=31536000, immutable", br.Header().Get("Cache-Control"), "Fingerprinted file not immutable")
+
+	gz := serve(h, "GET", "/assets/app.3f9a2c1d.js", nil, http.Header{"Accept-Encoding": {"gzip"}})
+	assert.Equal(t, "gzip", gz.Header().Get("Content-Encoding"), "Gzip sibling not used")
+
+	plain := serve(h, "GET", "/assets/app.3f9a2c1d.js", nil, nil)
+	assert.Equal(t, "", plain.Header().Get("Content-Encoding"), "Sibling used without Accept-Encoding")
+	assert.Equal(t, "console.log('app')", plain.Body.String(), "Body does not match")
+
+	assert.NotEqual(t, plain.Header().Get("ETag"), gz.Header().Get("ETag"), "Representations must have their own ETag")
+	assert.NotEqual(t, br.Header().Get("ETag"), gz.Header().Get("ETag"), "Representations must have their own ETag")
+
+	//the compression middleware leaves precompressed files alone
+	compressed := serve(Compression(h), "GET", "/assets/app.3f9a2c1d.js", nil, http.Header{"Accept-Encoding": {"br"}})
+	assert.Equal(t, "brotli bytes", compressed.Body.String(), "Precompressed file compressed again")
+	assert.Equal(t, []string{"Accept-Encoding"}, compressed.Header().Values("Vary"), "Vary header duplicated")
+}
+
+func Test_Static_ETagAndRange(t *testing.T) {
+	h := NewStatic(staticFS(), StaticOptions{})
+
+	first := serve(h, "GET", "/docs/readme.txt", nil, nil)
+	etag := first.Header().Get("ETag")
+	assert.NotEmpty(t, etag, "ETag missing")
+	assert.Equal(t, "no-cache", first.Header().Get("Cache-Control"), "Cache-Control does not match")
+
+	notModified := serve(h, "GET", "/docs/readme.txt", nil, http.Header{"If-None-Match": {etag}})
+	assert.Equal(t, http.StatusNotModified, notModified.Code, "ETag not honoured")
+
+	partial := serve(h, "GET", "/docs/readme.txt", nil, http.Header{"Range": {"bytes=2-4"}})
+	assert.Equal(t, http.StatusPartialContent, partial.Code, "Range not honoured")
+	assert.Equal(t, "234", partial.Body.String(), "Range body does not match")
+
+	sniffed := serve(h, "GET", "/data/blob", nil, nil)
+	assert.Equal(t, "image/png", sniffed.Header().Get("Content-Type"), "Content-Type not sniffed")
+}
+
+func Test_Static_IndexAndFallback(t *testing.T) {
+	h := NewStatic(staticFS(), StaticOptions{})
+	assert.Equal(t, "<html>app</html>", serve(h, "GET", "/", nil, nil).Body.String(), "Index not served")
+	assert.Equal(t, http.StatusNotFound, serve(h, "GET", "/users/7", nil, nil).Code, "Fallback used without SPAFallback")
+	assert.Equal(t, http.StatusNotFound, serve(h, "GET", "/docs", nil, nil).Code, "Directory without index served")
+	assert.Equal(t, http.StatusNotFound, serve(h, "GET", "/../secret", nil, nil).Code, "Path escaped the root")
+	assert.Equal(t, http.StatusMethodNotAllowed, serve(h, "POST", "/", nil, nil).Code, "POST accepted")
+
+	spa := NewStatic(staticFS(), StaticOptions{SPAFallback: true})
+	route := serve(spa, "GET", "/users/7", nil, nil)
+	assert.Equal(t, http.StatusOK, route.Code, "Client side route not served")
+	assert.Equal(t, "<html>app</html>", route.Body.String(), "Index not served for a client side route")
+	assert.Equal(t, http.StatusNotFound, serve(spa, "GET", "/assets/missing.js", nil, nil).Code, "Fallback used for a missing asset")
+}
+
+func Test_Fingerprinted(t *testing.T) {
+	assert.True(t, Fingerprinted("assets/app.3f9a2c1d.js"), "Hex hash not recognised")
+	assert.True(t, Fingerprinted("chunk-BX7fw9Qa.css"), "Base64 hash not recognised")
+	assert.False(t, Fingerprinted("index.html"), "Plain name recognised")
+	assert.False(t, Fingerprinted("settings-template.html"), "Word recognised as hash")
+	assert.True(t, Fingerprinted("vendor.1a2b3c4d5e6f7a8b9c0d.js"), "20 character hex hash not recognised")
+	assert.False(t, Fingerprinted("site-header2024.css"), "Name with a year recognised as hash")
+	assert.False(t, Fingerprinted("app.20241231.js"), "Date recognised as hash")
+	assert.False(t, Fingerprinted("release1.js"), "Name without separator recognised as hash")
+	assert.False(t, Fingerprinted("app.3f9a2c1d0.js"), "Hex hash of odd length recognised")
+}
+
+//brokenFS finds every file of the MapFS but fails to read them
+type brokenFS struct {
+	fstest.MapFS
+}
+
+func (f brokenFS) Open(name string) (fs.File, error) {
+	file, err := f.MapFS.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return brokenFile{file}, nil
+}
+
+type brokenFile struct {
+	fs.File
+}
+
+func (brokenFile) Stat() (fs.FileInfo, error) {
+	return nil, errors.New("read /srv/public/index.html: input/output error")
+}
+
+func Test_Static_FileSystemError(t *testing.T) {
+	h := NewStatic(brokenFS{staticFS()}, StaticOptions{})
+	rr := serve(h, "GET", "/", nil, nil)
+	assert.Equal(t, http.StatusInternalServerError, rr.Code, "Status does not match")
+	assert.Equal(t, "Internal Server Error\n", rr.Body.String(), "File system error sent to the client")
+}